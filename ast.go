@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tuxikus/mdfmt/markdown"
+)
+
+// astCommand runs "mdfmt ast", which writes the syntax tree of a file, or
+// of in if no path is given, to out. With -decode it reads a tree instead and
+// writes it formatted as Markdown.
+func astCommand(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := flags.String("format", "json", "output `format`, only json is supported")
+	decode := flags.Bool("decode", false, "read a syntax tree and write it as formatted Markdown")
+	addStyleFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mdfmt ast [flags] [path]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *format != "json" {
+		return &usageError{fmt.Errorf("unknown format %q", *format)}
+	}
+	if flags.NArg() > 1 {
+		return &usageError{fmt.Errorf("ast takes at most one path")}
+	}
+
+	path := stdinName
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	opts, err := optionsFor(path, flagOptions)
+	if err != nil {
+		return err
+	}
+
+	if *decode {
+		node, err := markdown.DecodeJSON(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		var buf bytes.Buffer
+		if err := markdown.Format(&buf, node, opts); err != nil {
+			return withFile(err, path)
+		}
+		_, err = out.Write(buf.Bytes())
+		return err
+	}
+
+	doc, err := markdown.ParseWithOptions(in, opts)
+	if err != nil {
+		return withFile(err, path)
+	}
+
+	return markdown.EncodeJSON(out, doc)
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tuxikus/mdfmt/markdown"
+)
+
+// configNames are the configuration file names looked for in every
+// directory, in order of precedence.
+var configNames = []string{".mdfmt.toml", ".mdfmt.yaml", ".mdfmt.yml"}
+
+// styleOptions are the formatting options that can be set by flags and in
+// configuration files.
+var styleOptions = []struct {
+	name  string
+	bool  bool
+	usage string
+}{
+	{"numbering", false, "ordered list numbering: sequential, ones or preserve (default sequential)"},
+	{"bullet", false, "bullet list marker: preserve, -, *, + or alternate (default preserve)"},
+	{"wrap", false, "paragraph wrapping: preserve, always or never (default preserve)"},
+	{"width", false, "line width for -wrap always (default 80)"},
+	{"tabwidth", false, "number of spaces a tab indents a list element by (default 4)"},
+	{"listindent", false, "indentation of nested lists (default 2)"},
+	{"tablepadding", false, "spaces between table pipes and cell text (default 1)"},
+	{"headingspace", true, "separate headings from the next block by a blank line (default true)"},
+	{"finalnewline", true, "end the output with a newline (default true)"},
+	{"lineending", false, "line ending: lf or crlf (default lf)"},
+}
+
+// option is a single style option as written on the command line or in a
+// configuration file.
+type option struct {
+	key   string
+	value string
+	line  int
+}
+
+// applyOption sets the style option key of opts to value.
+func applyOption(opts *markdown.FormatOptions, key, value string) error {
+	var err error
+
+	switch key {
+	case "numbering":
+		opts.Numbering, err = markdown.ParseNumberingStyle(value)
+	case "bullet":
+		opts.Bullet, err = markdown.ParseBulletStyle(value)
+	case "wrap":
+		opts.Wrap, err = markdown.ParseProseWrap(value)
+	case "width":
+		opts.WrapWidth, err = parseCount(value)
+	case "tabwidth":
+		opts.TabWidth, err = parseCount(value)
+	case "listindent":
+		opts.ListIndent, err = parseCount(value)
+	case "tablepadding":
+		opts.TablePadding, err = parseCount(value)
+	case "headingspace":
+		opts.BlankLineAfterHeading, err = strconv.ParseBool(value)
+	case "finalnewline":
+		opts.FinalNewline, err = strconv.ParseBool(value)
+	case "lineending":
+		opts.LineEnding, err = parseLineEnding(value)
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+
+	return err
+}
+
+// parseCount parses a non-negative number.
+func parseCount(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+
+	return n, nil
+}
+
+// parseLineEnding parses a line ending name as used by EditorConfig.
+func parseLineEnding(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "lf":
+		return "\n", nil
+	case "crlf":
+		return "\r\n", nil
+	}
+
+	return "", fmt.Errorf("invalid line ending %q", value)
+}
+
+// config is a parsed configuration file. The options of the overrides whose
+// glob matches a file are applied after the top level options.
+type config struct {
+	path      string
+	options   []option
+	overrides []override
+}
+
+type override struct {
+	glob    string
+	options []option
+}
+
+// readConfig reads and validates the configuration file at path.
+func readConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg *config
+	if filepath.Ext(path) == ".toml" {
+		cfg, err = parseTOMLConfig(path, string(data))
+	} else {
+		cfg, err = parseYAMLConfig(path, string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// report unknown keys and invalid values before formatting anything
+	scratch := markdown.DefaultFormatOptions()
+	sets := [][]option{cfg.options}
+	for _, o := range cfg.overrides {
+		sets = append(sets, o.options)
+	}
+	for _, options := range sets {
+		for _, o := range options {
+			if err := applyOption(&scratch, o.key, o.value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, o.line, err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseTOMLConfig parses the subset of TOML used by .mdfmt.toml: key/value
+// pairs with string, integer and boolean values and [overrides."glob"]
+// tables.
+//
+//	wrap = "always"
+//	width = 80
+//
+//	[overrides."CHANGELOG.md"]
+//	bullet = "*"
+func parseTOMLConfig(path, data string) (*config, error) {
+	cfg := &config{path: path}
+	options := &cfg.options
+
+	for i, line := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutPrefix(line, "[overrides.")
+			if !ok || !strings.HasSuffix(name, "]") {
+				return nil, fmt.Errorf("%s:%d: unknown table %s", path, lineNo, line)
+			}
+			glob, err := unquote(strings.TrimSpace(strings.TrimSuffix(name, "]")))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+
+			cfg.overrides = append(cfg.overrides, override{glob: glob})
+			options = &cfg.overrides[len(cfg.overrides)-1].options
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			var err error
+			if value, err = unquote(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+
+		*options = append(*options, option{key, value, lineNo})
+	}
+
+	return cfg, nil
+}
+
+// parseYAMLConfig parses the subset of YAML used by .mdfmt.yaml: key/value
+// pairs and an overrides mapping from globs to key/value pairs.
+//
+//	wrap: always
+//	width: 80
+//	overrides:
+//	  CHANGELOG.md:
+//	    bullet: "*"
+func parseYAMLConfig(path, data string) (*config, error) {
+	cfg := &config{path: path}
+	inOverrides := false
+	globIndent := -1
+
+	for i, line := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line = strings.TrimRight(stripComment(line), " \r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return nil, fmt.Errorf("%s:%d: tabs are not allowed for indentation", path, lineNo)
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key: value", path, lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			var err error
+			if value, err = unquote(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+
+		switch {
+		case indent == 0 && key == "overrides" && value == "":
+			inOverrides = true
+			globIndent = -1
+		case indent == 0:
+			inOverrides = false
+			cfg.options = append(cfg.options, option{key, value, lineNo})
+		case !inOverrides:
+			return nil, fmt.Errorf("%s:%d: unexpected indentation", path, lineNo)
+		case (globIndent == -1 || indent == globIndent) && value == "":
+			glob, err := unquote(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+			globIndent = indent
+			cfg.overrides = append(cfg.overrides, override{glob: glob})
+		case globIndent != -1 && indent > globIndent:
+			o := &cfg.overrides[len(cfg.overrides)-1]
+			o.options = append(o.options, option{key, value, lineNo})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a glob with options below overrides", path, lineNo)
+		}
+	}
+
+	return cfg, nil
+}
+
+// stripComment removes a # comment that is not inside quotes from line.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// unquote removes the quotes around a double or single quoted string, other
+// strings are returned unchanged.
+func unquote(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+
+	return s, nil
+}
+
+var (
+	configMu    sync.Mutex
+	configCache = map[string]*config{}
+)
+
+// findConfig returns the nearest configuration file in dir or one of its
+// parents, or nil if there is none.
+func findConfig(dir string) (*config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	visited := make([]string, 0)
+	var cfg *config
+	for {
+		if cached, ok := configCache[dir]; ok {
+			cfg = cached
+			break
+		}
+		visited = append(visited, dir)
+
+		found := ""
+		for _, name := range configNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				found = filepath.Join(dir, name)
+				break
+			}
+		}
+		if found != "" {
+			var err error
+			if cfg, err = readConfig(found); err != nil {
+				return nil, &usageError{err}
+			}
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	for _, dir := range visited {
+		configCache[dir] = cfg
+	}
+
+	return cfg, nil
+}
+
+// matchGlob reports whether the slash separated path name matches pattern.
+// A ** element matches any number of directories and a pattern without a
+// slash is matched against the last element of name only.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// optionsFor returns the format options for the file at path: the defaults,
+// changed by the EditorConfig sections matching path, the nearest
+// configuration file, its overrides matching path and finally the flags.
+// Standard input, which has no directory, uses the configuration of the
+// working directory.
+func optionsFor(path string, flagOptions []option) (markdown.FormatOptions, error) {
+	opts := markdown.DefaultFormatOptions()
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return opts, err
+	}
+	cfg, err := findConfig(filepath.Dir(abs))
+	if err != nil {
+		return opts, err
+	}
+
+	options, err := editorConfigOptions(abs)
+	if err != nil {
+		return opts, err
+	}
+	if cfg != nil {
+		options = append(options, cfg.options...)
+
+		rel, err := filepath.Rel(filepath.Dir(cfg.path), abs)
+		if err != nil {
+			return opts, err
+		}
+		for _, o := range cfg.overrides {
+			if matchGlob(o.glob, filepath.ToSlash(rel)) {
+				options = append(options, o.options...)
+			}
+		}
+	}
+	options = append(options, flagOptions...)
+
+	for _, o := range options {
+		if err := applyOption(&opts, o.key, o.value); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tuxikus/mdfmt/markdown"
+)
+
+func TestParseTOMLConfig(t *testing.T) {
+	data := `# house style
+wrap = "always" # trailing comment
+width = 72
+
+[overrides."CHANGELOG.md"]
+bullet = '*'
+`
+	want := &config{
+		path: ".mdfmt.toml",
+		options: []option{
+			{"wrap", "always", 2},
+			{"width", "72", 3},
+		},
+		overrides: []override{
+			{"CHANGELOG.md", []option{{"bullet", "*", 6}}},
+		},
+	}
+
+	got, err := parseTOMLConfig(".mdfmt.toml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestParseYAMLConfig(t *testing.T) {
+	data := `---
+wrap: always
+width: 72 # columns
+overrides:
+  "docs/**/*.md":
+    numbering: ones
+  CHANGELOG.md:
+    bullet: "*"
+headingspace: false
+`
+	want := &config{
+		path: ".mdfmt.yaml",
+		options: []option{
+			{"wrap", "always", 2},
+			{"width", "72", 3},
+			{"headingspace", "false", 9},
+		},
+		overrides: []override{
+			{"docs/**/*.md", []option{{"numbering", "ones", 6}}},
+			{"CHANGELOG.md", []option{{"bullet", "*", 8}}},
+		},
+	}
+
+	got, err := parseYAMLConfig(".mdfmt.yaml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{".mdfmt.toml", "wrap = \"always\"\nwidht = 80\n", ".mdfmt.toml:2: unknown key \"widht\""},
+		{".mdfmt.toml", "[overrides.\"*.md\"]\nbullet = \"x\"\n", ".mdfmt.toml:2: unknown bullet style \"x\""},
+		{".mdfmt.toml", "[format]\n", ".mdfmt.toml:1: unknown table [format]"},
+		{".mdfmt.toml", "wrap\n", ".mdfmt.toml:1: expected key = value"},
+		{".mdfmt.yaml", "width: -1\n", ".mdfmt.yaml:1: invalid number \"-1\""},
+		{".mdfmt.yaml", "wrap: always\n  width: 80\n", ".mdfmt.yaml:2: unexpected indentation"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.name)
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := readConfig(path)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("want error %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"CHANGELOG.md", "CHANGELOG.md", true},
+		{"CHANGELOG.md", "sub/CHANGELOG.md", true},
+		{"*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/**/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/sub/deep/a.md", true},
+		{"docs/**", "docs/sub/a.md", true},
+		{"docs/**/*.md", "other/a.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsFor(t *testing.T) {
+	root := t.TempDir()
+	config := `wrap = "always"
+width = 60
+
+[overrides."CHANGELOG.md"]
+bullet = "*"
+`
+	if err := os.WriteFile(filepath.Join(root, ".mdfmt.toml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "docs", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := markdown.DefaultFormatOptions()
+	want.Wrap = markdown.ProseWrapAlways
+	want.WrapWidth = 40
+	want.Bullet = markdown.BulletAsterisk
+
+	flags := []option{{key: "width", value: "40"}}
+	got, err := optionsFor(filepath.Join(root, "docs", "sub", "CHANGELOG.md"), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	want.Bullet = markdown.BulletPreserve
+	got, err = optionsFor(filepath.Join(root, "docs", "README.md"), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
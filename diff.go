@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// diffContext is the number of unchanged lines shown around every change.
+const diffContext = 3
+
+// edit is a single line of an edit script, op is ' ' for an unchanged line,
+// '-' for a deleted and '+' for an inserted one.
+type edit struct {
+	op   byte
+	line string
+}
+
+// splitLines splits text into lines, keeping the line terminators.
+func splitLines(text []byte) []string {
+	lines := make([]string, 0)
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+		lines = append(lines, string(text[:i]))
+		text = text[i:]
+	}
+
+	return lines
+}
+
+// editScript returns a shortest edit script turning a into b using Myers'
+// O(ND) difference algorithm.
+func editScript(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := make([][]int, 0)
+
+	// find the length of the shortest edit script, remembering the furthest
+	// reaching paths of every round for the backtracking below; round d only
+	// reads the diagonals -d-1 to d+1, so only those are kept, indexed by
+	// k+d+1
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	edits := make([]edit, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v[d+k] < v[d+k+2]) {
+			prevK = k + 1
+		}
+		prevX := v[d+1+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+// unifiedDiff returns the differences between a and b in unified diff
+// format, or nil if they are equal.
+func unifiedDiff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	edits := editScript(splitLines(a), splitLines(b))
+
+	// line numbers in a and b before every edit
+	aLines := make([]int, len(edits)+1)
+	bLines := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if e.op != '+' {
+			aLines[i+1]++
+		}
+		if e.op != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		// changes at most two contexts apart share a hunk
+		end := i
+		for j := i; j < len(edits) && j-end <= 2*diffContext; j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		start := max(i-diffContext, 0)
+		stop := min(end+diffContext, len(edits))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aLines[start], aLines[stop]-aLines[start]),
+			hunkRange(bLines[start], bLines[stop]-bLines[start]))
+		for _, e := range edits[start:stop] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if len(e.line) == 0 || e.line[len(e.line)-1] != '\n' {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = stop
+	}
+
+	return out.Bytes()
+}
+
+// hunkRange formats the range of count lines after line start of a hunk
+// header.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
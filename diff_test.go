@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	if got := unifiedDiff("a", "b", []byte("same\n"), []byte("same\n")); got != nil {
+		t.Errorf("want no diff, got %q", got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n15\n16\n"
+	want := `--- a.md.orig
++++ a.md
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -11,5 +11,5 @@
+ 11
+ 12
+ 13
+-14
+ 15
++16
+`
+
+	if got := string(unifiedDiff("a.md.orig", "a.md", []byte(a), []byte(b))); got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiffMergesCloseChanges(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	b := "one\n2\n3\n4\n5\n6\n7\neight\n"
+
+	got := string(unifiedDiff("a", "b", []byte(a), []byte(b)))
+	if n := strings.Count(got, "@@ -"); n != 1 {
+		t.Errorf("want one hunk, got\n%s", got)
+	}
+	if !strings.Contains(got, "@@ -1,8 +1,8 @@\n") {
+		t.Errorf("want a hunk covering all lines, got\n%s", got)
+	}
+}
+
+func TestUnifiedDiffNoNewlineAtEnd(t *testing.T) {
+	want := `--- a
++++ b
+@@ -1,2 +1,2 @@
+ # header
+-text
+\ No newline at end of file
++text
+`
+
+	if got := string(unifiedDiff("a", "b", []byte("# header\ntext"), []byte("# header\ntext\n"))); got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiffEmpty(t *testing.T) {
+	want := `--- a
++++ b
+@@ -0,0 +1 @@
++text
+`
+
+	if got := string(unifiedDiff("a", "b", nil, []byte("text\n"))); got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestEditScriptLarge(t *testing.T) {
+	// every third line is inserted and every fifth deleted, except for the
+	// lines in both sets
+	var a, b []string
+	want := 0
+	for i := range 5000 {
+		line := fmt.Sprintf("%d\n", i)
+		if i%3 != 0 {
+			a = append(a, line)
+		}
+		if i%5 != 0 {
+			b = append(b, line)
+		}
+		if (i%3 == 0) != (i%5 == 0) {
+			want++
+		}
+	}
+
+	var gotA, gotB []string
+	changes := 0
+	for _, e := range editScript(a, b) {
+		if e.op != '+' {
+			gotA = append(gotA, e.line)
+		}
+		if e.op != '-' {
+			gotB = append(gotB, e.line)
+		}
+		if e.op != ' ' {
+			changes++
+		}
+	}
+
+	if !slices.Equal(a, gotA) || !slices.Equal(b, gotB) {
+		t.Error("edit script does not turn a into b")
+	}
+	if changes != want {
+		t.Errorf("want %d changes, got %d", want, changes)
+	}
+}
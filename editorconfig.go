@@ -0,0 +1,292 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// editorConfig is a parsed .editorconfig file.
+type editorConfig struct {
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+type editorConfigSection struct {
+	glob  string
+	props map[string]string
+}
+
+// parseEditorConfig parses the .editorconfig file data found in dir. Keys
+// and values are lower cased, invalid lines are ignored as the
+// specification asks for.
+func parseEditorConfig(dir, data string) *editorConfig {
+	ec := &editorConfig{dir: dir}
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if strings.HasSuffix(line, "]") {
+				ec.sections = append(ec.sections, editorConfigSection{
+					glob:  line[1 : len(line)-1],
+					props: map[string]string{},
+				})
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		if len(ec.sections) == 0 {
+			if key == "root" {
+				ec.root = value == "true"
+			}
+			continue
+		}
+		ec.sections[len(ec.sections)-1].props[key] = value
+	}
+
+	return ec
+}
+
+var (
+	editorConfigMu    sync.Mutex
+	editorConfigCache = map[string]*editorConfig{}
+)
+
+// readEditorConfig returns the .editorconfig file in dir, or nil if there is
+// none.
+func readEditorConfig(dir string) (*editorConfig, error) {
+	editorConfigMu.Lock()
+	defer editorConfigMu.Unlock()
+
+	if ec, ok := editorConfigCache[dir]; ok {
+		return ec, nil
+	}
+
+	var ec *editorConfig
+	data, err := os.ReadFile(filepath.Join(dir, ".editorconfig"))
+	switch {
+	case err == nil:
+		ec = parseEditorConfig(dir, string(data))
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	editorConfigCache[dir] = ec
+
+	return ec, nil
+}
+
+// editorConfigProperties returns the EditorConfig properties for the file
+// at the absolute path abs. Files closer to abs and later sections take
+// precedence.
+func editorConfigProperties(abs string) (map[string]string, error) {
+	files := make([]*editorConfig, 0)
+	for dir := filepath.Dir(abs); ; {
+		ec, err := readEditorConfig(dir)
+		if err != nil {
+			return nil, err
+		}
+		if ec != nil {
+			files = append(files, ec)
+			if ec.root {
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, abs)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range files[i].sections {
+			if !matchEditorConfigGlob(s.glob, filepath.ToSlash(rel)) {
+				continue
+			}
+			for k, v := range s.props {
+				props[k] = v
+			}
+		}
+	}
+
+	return props, nil
+}
+
+// editorConfigOptions maps the EditorConfig properties of the file at the
+// absolute path abs onto style options. Unset and invalid properties are
+// ignored.
+func editorConfigOptions(abs string) ([]option, error) {
+	props, err := editorConfigProperties(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]option, 0)
+	isCount := func(value string) bool {
+		_, err := parseCount(value)
+		return err == nil
+	}
+
+	// tab_width defaults to indent_size and indent_size = tab to tab_width
+	indent, tab := props["indent_size"], props["tab_width"]
+	if indent == "tab" {
+		indent = tab
+	}
+	if tab == "" {
+		tab = indent
+	}
+	if isCount(tab) {
+		options = append(options, option{key: "tabwidth", value: tab})
+	}
+	if isCount(indent) {
+		options = append(options, option{key: "listindent", value: indent})
+	}
+
+	if v := props["max_line_length"]; isCount(v) {
+		options = append(options, option{key: "width", value: v})
+	}
+	if v := props["end_of_line"]; v == "lf" || v == "crlf" {
+		options = append(options, option{key: "lineending", value: v})
+	}
+	if v := props["insert_final_newline"]; v == "true" || v == "false" {
+		options = append(options, option{key: "finalnewline", value: v})
+	}
+
+	return options, nil
+}
+
+// matchEditorConfigGlob reports whether the slash separated path name,
+// relative to the directory of the .editorconfig file, matches the section
+// glob pattern. Globs without a slash match the file name anywhere.
+func matchEditorConfigGlob(pattern, name string) bool {
+	switch {
+	case strings.HasPrefix(pattern, "/"):
+		pattern = pattern[1:]
+	case !strings.Contains(pattern, "/"):
+		pattern = "**/" + pattern
+	}
+
+	re, err := regexp.Compile("^" + editorConfigRegexp(pattern) + "$")
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(name)
+}
+
+// numericRange matches the {num1..num2} glob.
+var numericRange = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}`)
+
+// editorConfigRegexp translates an EditorConfig glob into a regular
+// expression.
+func editorConfigRegexp(pattern string) string {
+	var sb strings.Builder
+	depth := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				// **/ also matches no directory at all
+				sb.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end == -1 {
+				sb.WriteString(`\[`)
+				break
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end
+		case '{':
+			if m := numericRange.FindStringSubmatch(pattern[i:]); m != nil {
+				sb.WriteString(numericRangeRegexp(m[1], m[2]))
+				i += len(m[0]) - 1
+				break
+			}
+			// braces without a comma are literal
+			if end := strings.IndexByte(pattern[i:], '}'); end == -1 || !strings.Contains(pattern[i:i+end], ",") {
+				sb.WriteString(`\{`)
+				break
+			}
+			depth++
+			sb.WriteString("(?:")
+		case '}':
+			if depth == 0 {
+				sb.WriteString(`\}`)
+				break
+			}
+			depth--
+			sb.WriteString(")")
+		case ',':
+			if depth == 0 {
+				sb.WriteString(",")
+				break
+			}
+			sb.WriteString("|")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
+
+// numericRangeRegexp returns a regular expression matching the integers
+// from lo to hi.
+func numericRangeRegexp(lo, hi string) string {
+	from, _ := strconv.Atoi(lo)
+	to, _ := strconv.Atoi(hi)
+	if from > to {
+		from, to = to, from
+	}
+	// keep the expression small, larger ranges match any integer
+	if to-from > 1000 {
+		return `-?\d+`
+	}
+
+	alts := make([]string, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		alts = append(alts, strconv.Itoa(n))
+	}
+
+	return "(?:" + strings.Join(alts, "|") + ")"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuxikus/mdfmt/markdown"
+)
+
+func TestMatchEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "README.md", true},
+		{"*.md", "docs/guide/README.md", true},
+		{"*.md", "README.txt", false},
+		{"*.{md,markdown}", "docs/notes.markdown", true},
+		{"/*.md", "docs/README.md", false},
+		{"/*.md", "README.md", true},
+		{"docs/*.md", "docs/README.md", true},
+		{"docs/*.md", "docs/sub/README.md", false},
+		{"docs/**.md", "docs/sub/README.md", true},
+		{"docs/**/*.md", "docs/README.md", true},
+		{"[Rr]EADME.md", "readme.md", false},
+		{"[!a-q]EADME.md", "README.md", true},
+		{"ch?.md", "ch1.md", true},
+		{"ch{1..3}.md", "ch2.md", true},
+		{"ch{1..3}.md", "ch4.md", false},
+		{"{README}.md", "{README}.md", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchEditorConfigGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchEditorConfigGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsForEditorConfig(t *testing.T) {
+	root := t.TempDir()
+	parent := `root = true
+
+[*]
+indent_size = 4
+end_of_line = lf
+
+[*.md]
+indent_size = 2
+tab_width = 8
+max_line_length = 72
+insert_final_newline = false
+`
+	child := `; closer files take precedence
+[*.{md,markdown}]
+end_of_line = CRLF
+max_line_length = off
+`
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".editorconfig"), []byte(parent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", ".editorconfig"), []byte(child), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", ".mdfmt.toml"), []byte("finalnewline = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := markdown.DefaultFormatOptions()
+	want.ListIndent = 2
+	want.TabWidth = 8
+	want.WrapWidth = 72
+	want.FinalNewline = false
+
+	got, err := optionsFor(filepath.Join(root, "README.md"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	// the .mdfmt.toml and the flags take precedence over EditorConfig
+	want.LineEnding = "\r\n"
+	want.WrapWidth = 80
+	want.FinalNewline = true
+	want.ListIndent = 3
+
+	flags := []option{{key: "listindent", value: "3"}}
+	got, err = optionsFor(filepath.Join(root, "docs", "guide.md"), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreNames are the files whose patterns exclude paths from directory
+// walks, both use the .gitignore syntax.
+var ignoreNames = []string{".gitignore", ".mdfmtignore"}
+
+// skipDirs are the directories never walked into.
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+var (
+	// globs set by -include and -exclude, matched against paths relative
+	// to the walked directory
+	includeGlobs []string
+	excludeGlobs []string
+)
+
+// ignoreRule is a pattern of an ignore file in the absolute directory dir.
+type ignoreRule struct {
+	dir     string
+	pattern []string
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnore parses the .gitignore style patterns in data.
+func parseIgnore(dir, data string) []ignoreRule {
+	rules := make([]ignoreRule, 0)
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := ignoreRule{dir: dir}
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// patterns with a slash before the end are relative to dir, others
+		// match at any depth
+		if strings.Contains(line, "/") {
+			rule.pattern = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		} else {
+			rule.pattern = []string{"**", line}
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// readIgnoreFiles returns the rules of the ignore files in the absolute
+// directory dir.
+func readIgnoreFiles(dir string) ([]ignoreRule, error) {
+	rules := make([]ignoreRule, 0)
+
+	for _, name := range ignoreNames {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parseIgnore(dir, string(data))...)
+	}
+
+	return rules, nil
+}
+
+// ancestorIgnoreRules returns the rules of the ignore files above the
+// absolute directory dir, up to the root of the git repository containing
+// it. Outside of a repository there are none.
+func ancestorIgnoreRules(dir string) ([]ignoreRule, error) {
+	isRepo := func(dir string) bool {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		return err == nil
+	}
+	dirs := make([]string, 0)
+	for !isRepo(dir) {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+
+	rules := make([]ignoreRule, 0)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dirRules, err := readIgnoreFiles(dirs[i])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, dirRules...)
+	}
+
+	return rules, nil
+}
+
+// ignored reports whether the absolute path is ignored by rules. Rules of
+// deeper ignore files come later and the last matching rule wins.
+func ignored(rules []ignoreRule, abs string, isDir bool) bool {
+	ignore := false
+
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(r.dir, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if matchElems(r.pattern, strings.Split(filepath.ToSlash(rel), "/")) {
+			ignore = !r.negate
+		}
+	}
+
+	return ignore
+}
+
+// validGlob reports whether every element of the slash separated pattern is
+// a valid path.Match pattern.
+func validGlob(pattern string) bool {
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findMarkdownFiles returns the Markdown files below the directory root in
+// lexical order. Directories in skipDirs, paths ignored by .gitignore and
+// .mdfmtignore files and paths matching an -exclude glob are skipped. With
+// -include globs only the Markdown files matching one of them are returned.
+// Errors for single entries are reported and skipped.
+func findMarkdownFiles(root string) ([]string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ancestorIgnoreRules(absRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			report(err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		abs := filepath.Join(absRoot, rel)
+
+		if d.IsDir() {
+			if p != root && (skipDirs[d.Name()] || ignored(rules, abs, true) || matchAny(excludeGlobs, rel)) {
+				return filepath.SkipDir
+			}
+
+			// rules of sibling directories never match, so they can stay
+			dirRules, err := readIgnoreFiles(abs)
+			if err != nil {
+				report(err)
+			}
+			rules = append(rules, dirRules...)
+			return nil
+		}
+
+		if !isMarkdownFile(p) || len(includeGlobs) > 0 && !matchAny(includeGlobs, rel) {
+			return nil
+		}
+		if ignored(rules, abs, false) || matchAny(excludeGlobs, rel) {
+			return nil
+		}
+
+		files = append(files, p)
+		return nil
+	})
+
+	return files, err
+}
+
+// matchAny reports whether name matches one of the globs.
+func matchAny(globs []string, name string) bool {
+	for _, g := range globs {
+		if matchGlob(g, name) {
+			return true
+		}
+	}
+
+	return false
+}
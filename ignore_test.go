@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	rules := parseIgnore("/repo", `# build output
+/build/
+*.tmp
+!keep.tmp
+docs/**/draft-*.md
+\#notes.md
+`)
+	rules = append(rules, parseIgnore("/repo/docs", "!draft-b.md\n")...)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/repo/build", true, true},
+		{"/repo/build", false, false},
+		{"/repo/src/build", true, false},
+		{"/repo/a/b/c.tmp", false, true},
+		{"/repo/a/keep.tmp", false, false},
+		{"/repo/docs/draft-a.md", false, true},
+		{"/repo/docs/x/y/draft-a.md", false, true},
+		{"/repo/docs/draft-b.md", false, false},
+		{"/repo/#notes.md", false, true},
+		{"/repo/README.md", false, false},
+		{"/other/c.tmp", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := ignored(rules, tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestFindMarkdownFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":                 "generated/\n",
+		".mdfmtignore":               "CHANGELOG.md\n",
+		"README.md":                  "",
+		"CHANGELOG.md":               "",
+		"notes.txt":                  "",
+		"docs/guide.markdown":        "",
+		"docs/api/index.md":          "",
+		"docs/api/schema.json":       "",
+		"docs/api/.gitignore":        "old.md\n",
+		"docs/api/old.md":            "",
+		"generated/out.md":           "",
+		"node_modules/pkg/README.md": "",
+		"vendor/mod/README.md":       "",
+		".git/description.md":        "",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		include, exclude []string
+		want             []string
+	}{
+		{nil, nil, []string{"README.md", "docs/api/index.md", "docs/guide.markdown"}},
+		{nil, []string{"docs/api"}, []string{"README.md", "docs/guide.markdown"}},
+		{[]string{"*.txt", "docs/**/*.md"}, nil, []string{"docs/api/index.md"}},
+		{[]string{"docs/**/*"}, nil, []string{"docs/api/index.md", "docs/guide.markdown"}},
+	}
+
+	defer func() { includeGlobs, excludeGlobs = nil, nil }()
+	for _, tt := range tests {
+		includeGlobs, excludeGlobs = tt.include, tt.exclude
+
+		got, err := findMarkdownFiles(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			got[i] = filepath.ToSlash(got[i][len(root)+1:])
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("include %q exclude %q: want %q, got %q", tt.include, tt.exclude, tt.want, got)
+		}
+	}
+}
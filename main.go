@@ -1,468 +1,349 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
-)
+	"sync"
 
-// Markdown elements important for formatting:
-// Document:
-//
-// # Heading(level: 1, text: Heading)
-// Paragraph(text)
-//
-// ## Heading(level, text)
-// Paragraph(text)
-//
-// ### Heading(level, text)
-// (list)
-// - ListElement(level: 1, text: ListElement)
-// - ListElement
-// - ListElement
-//
-// * ListElement
-// * ListElement
-// * ListElement
-//
-// - ListElement
-//   - ListElement
-//     - ListElement(level: 3, text: ListElement)
-//     - ListElement
-//
-//
-// #### Heading
-// | Table | Table |
-// | ----- | ----- |
-// | Table | Table |
-//
-//
-
-const TabWidth = 4
-
-type NodeType int
+	"github.com/tuxikus/mdfmt/markdown"
+)
 
-const (
-	NodeTypeDocument = iota
-	NodeTypeHeading
-	NodeTypeParagraph
-	NodeTypeList
-	NodeTypeListElement
-	NodeTypeListEnd
-	NodeTypeTable
-	NodeTypeTableRow
-	NodeTypeTableElement
+var (
+	// style options set by flags, they take precedence over the
+	// configuration files
+	flagOptions []option
+
+	list     = flag.Bool("l", false, "list files whose formatting differs from mdfmt's")
+	write    = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	doDiff   = flag.Bool("d", false, "display diffs instead of rewriting files")
+	check    = flag.Bool("check", false, "exit with status 1 if any file is not formatted")
+	jobs     = flag.Int("j", runtime.GOMAXPROCS(0), "number of files formatted in parallel")
+	exitCode = 0
+	exitMu   sync.Mutex
 )
 
-type Node interface {
-	Type() NodeType
-	Children() []Node
-}
+// stdinName is the path reported for standard input.
+const stdinName = "<standard input>"
 
-var _ Node = (*Document)(nil)
+// Exit codes besides 1, which is used for unformatted files with -check and
+// for other errors, like an invalid syntax tree read by "mdfmt ast -decode".
+// The highest code of all errors is used.
+const (
+	exitUsage    = 2
+	exitIO       = 3
+	exitInternal = 4
+)
 
-type Document struct {
-	children []Node
+// usageError is an error in the flags or configuration files.
+type usageError struct {
+	err error
 }
 
-func (d *Document) Type() NodeType   { return NodeTypeDocument }
-func (d *Document) Children() []Node { return d.children }
-
-var _ Node = (*Heading)(nil)
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// exitCodeFor returns the exit code for err.
+func exitCodeFor(err error) int {
+	var (
+		pathErr    *fs.PathError
+		linkErr    *os.LinkError
+		syscallErr *os.SyscallError
+		usageErr   *usageError
+	)
+
+	switch {
+	case errors.Is(err, markdown.ErrInternal):
+		return exitInternal
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr):
+		return exitIO
+	case errors.As(err, &usageErr):
+		return exitUsage
+	}
 
-type Heading struct {
-	Level int
-	Text  string
+	return 1
 }
 
-func (h *Heading) Type() NodeType   { return NodeTypeHeading }
-func (h *Heading) Children() []Node { return nil }
-
-var _ Node = (*List)(nil)
-
-type List struct {
-	elements []Node
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	setExitCode(exitCodeFor(err))
 }
 
-func (l *List) Type() NodeType   { return NodeTypeList }
-func (l *List) Children() []Node { return l.elements }
-
-var _ Node = (*ListElement)(nil)
-
-type ListElement struct {
-	Level int
-	Text  string
+// setExitCode raises the exit code to code.
+func setExitCode(code int) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitCode = max(exitCode, code)
 }
 
-func (le *ListElement) Type() NodeType   { return NodeTypeListElement }
-func (le *ListElement) Children() []Node { return nil }
-
-var _ Node = (*ListEnd)(nil)
-
-type ListEnd struct{}
-
-func (le *ListEnd) Type() NodeType   { return NodeTypeListEnd }
-func (le *ListEnd) Children() []Node { return nil }
-
-var _ Node = (*Paragraph)(nil)
+// withFile sets the file of a *markdown.Error in err to path.
+func withFile(err error, path string) error {
+	var mdErr *markdown.Error
+	if errors.As(err, &mdErr) {
+		mdErr.File = path
+	}
 
-type Paragraph struct {
-	Text string
+	return err
 }
 
-func (p *Paragraph) Type() NodeType   { return NodeTypeParagraph }
-func (p *Paragraph) Children() []Node { return nil }
-
-var _ Node = (*Table)(nil)
-
-type Table struct {
-	rows []Node
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mdfmt [flags] [path ...]")
+	fmt.Fprintln(os.Stderr, "       mdfmt ast [flags] [path]")
+	flag.PrintDefaults()
 }
 
-func (t *Table) Type() NodeType   { return NodeTypeTable }
-func (t *Table) Children() []Node { return t.rows }
-
-var _ Node = (*TableRow)(nil)
-
-type TableRow struct {
-	elements []Node
+// isMarkdownFile reports whether path has a Markdown file extension.
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
 }
 
-func (tr *TableRow) Type() NodeType   { return NodeTypeTableRow }
-func (tr *TableRow) Children() []Node { return tr.elements }
-
-var _ Node = (*TableElement)(nil)
-
-type TableElement struct {
-	Text string
-}
+// processFile formats the file at path, or in if it is not nil, and writes
+// the result to out or, with -w, back to the file if it changed. With -l the
+// path and with -d a diff is written instead of the result, with -check
+// nothing is written.
+func processFile(path string, in io.Reader, out io.Writer) error {
+	perm := fs.FileMode(0o644)
+	if in == nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
 
-func (te *TableElement) Type() NodeType   { return NodeTypeTableElement }
-func (te *TableElement) Children() []Node { return nil }
-
-func dump(nodes []Node) {
-	for i := range nodes {
-		switch nodes[i].Type() {
-		case NodeTypeDocument:
-			fmt.Println("Document:")
-		case NodeTypeHeading:
-			fmt.Printf("Heading(lvl: %d, text: %s)\n", nodes[i].(*Heading).Level, nodes[i].(*Heading).Text)
-		case NodeTypeParagraph:
-			fmt.Printf("Paragraph(text: %s)\n", nodes[i].(*Paragraph).Text)
-		case NodeTypeList:
-			fmt.Println("List")
-		case NodeTypeListElement:
-			fmt.Printf("ListElement(lvl: %d, text: %s)\n", nodes[i].(*ListElement).Level, nodes[i].(*ListElement).Text)
-		case NodeTypeTable:
-			fmt.Println("Table")
-		case NodeTypeTableRow:
-			fmt.Println("TableRow")
-		case NodeTypeTableElement:
-			fmt.Printf("TableElement(text: %s)\n", nodes[i].(*TableElement).Text)
+		info, err := f.Stat()
+		if err != nil {
+			return err
 		}
+		perm = info.Mode().Perm()
+		in = f
+	}
 
-		dump(nodes[i].Children())
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
 	}
 
-}
+	opts, err := optionsFor(path, flagOptions)
+	if err != nil {
+		return err
+	}
 
-func Parse(in string) Node {
-	doc := &Document{}
+	doc, err := markdown.ParseWithOptions(bytes.NewReader(src), opts)
+	if err != nil {
+		return withFile(err, path)
+	}
 
-	lines := strings.Split(in, "\n")
+	var buf bytes.Buffer
+	if err := markdown.Format(&buf, doc, opts); err != nil {
+		return withFile(err, path)
+	}
+	res := buf.Bytes()
 
-	for i := 0; i < len(lines); i++ {
-		// skip empty lines
-		if strings.TrimSpace(lines[i]) == "" {
-			continue
+	if !bytes.Equal(src, res) {
+		if *list {
+			fmt.Fprintln(out, path)
 		}
-
-		// heading
-		if strings.HasPrefix(lines[i], "#") {
-			// get heading lvl
-			lvl := 0
-			textStart := 0
-			for j := range len(lines[i]) {
-				if lines[i][j] != '#' {
-					textStart = j + 1
-					break
-				}
-				lvl++
-			}
-
-			text := lines[i][textStart:]
-
-			doc.children = append(doc.children, &Heading{
-				Level: lvl,
-				Text:  text,
-			})
-
-			continue
+		if *check {
+			setExitCode(1)
 		}
-
-		// list
-		listStart := i
-		// trim all left spaces and check if - is first char
-		// - list element at lvl 1
-		//   - list element at lvl 2
-		//   - list element at lvl 2
-		//     - list element at lvl 3
-		if strings.HasPrefix(strings.TrimSpace(lines[i]), "-") {
-			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "-") {
-				i++
-			}
-
-			listElements := make([]Node, 0)
-			listLines := lines[listStart:i]
-
-			// replace tabs
-			for j := range listLines {
-				listLines[j] = strings.ReplaceAll(listLines[j], "\t", strings.Repeat(" ", TabWidth))
-			}
-
-			for _, listLine := range listLines {
-				lvl := 0
-				for j := range listLine {
-					if listLine[j] == '-' {
-						break
-					}
-					lvl++
-				}
-				// TODO: use j as text start, no need for trimming
-				text := strings.TrimSpace(listLine) // needed for higher lvl list elements
-				text = strings.TrimLeft(text, "-")
-				text = strings.TrimSpace(text)
-
-				lvl = lvl/2 + 1
-
-				listElements = append(listElements, &ListElement{
-					Level: lvl,
-					Text:  text,
-				})
+		if *write {
+			if err := writeFile(path, res, perm); err != nil {
+				return err
 			}
-
-			doc.children = append(doc.children, &List{
-				elements: listElements,
-			})
-
-			doc.children = append(doc.children, &ListEnd{})
-
-			// continue but dont increment
-			i--
-			continue
 		}
-
-		// table
-		tableStart := i
-		if strings.HasPrefix(lines[i], "|") {
-			for i < len(lines) && strings.HasPrefix(lines[i], "|") {
-				i++
+		if *doDiff {
+			if _, err := out.Write(unifiedDiff(path+".orig", path, src, res)); err != nil {
+				return err
 			}
-
-			tableRows := make([]Node, 0)
-			tableLines := lines[tableStart:i]
-
-			for j := range tableLines {
-				tableElements := make([]Node, 0)
-
-				// remove | from start and end
-				tableLine := tableLines[j][1 : len(tableLines[j])-1]
-				elems := strings.Split(tableLine, "|")
-
-				for k := range elems {
-					tableElements = append(tableElements, &TableElement{
-						Text: strings.TrimSpace(elems[k]),
-					})
-				}
-
-				tableRows = append(tableRows, &TableRow{
-					elements: tableElements,
-				})
-			}
-
-			doc.children = append(doc.children, &Table{
-				rows: tableRows,
-			})
-
-			i--
-			continue
 		}
+	}
 
-		// paragraph
-		paragraphStart := i
-		for i < len(lines) &&
-			!(strings.TrimSpace(lines[i]) == "" ||
-				strings.HasPrefix(lines[i], "-")) {
-			i++
-		}
-
-		doc.children = append(doc.children, &Paragraph{
-			Text: strings.Join(lines[paragraphStart:i], "\n"),
-		})
-
-		i--
+	if !*list && !*write && !*check && !*doDiff {
+		_, err = out.Write(res)
 	}
 
-	return doc
+	return err
 }
 
-func Fmt(document Node) string {
-	sb := strings.Builder{}
-	format(&sb, document.Children())
-	formatted := sb.String()
+// writeFile atomically replaces the file at path with data by writing to a
+// temporary file in the same directory and renaming it. A symbolic link is
+// kept, the file it points to is replaced instead.
+func writeFile(path string, data []byte, perm fs.FileMode) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
 
-	if formatted[len(formatted)-2:] == "\n\n" {
-		return formatted[:len(formatted)-2]
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".mdfmt*")
+	if err != nil {
+		return err
 	}
+	tmp := f.Name()
 
-	return formatted
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+
+	return err
 }
 
-func format(sb *strings.Builder, nodes []Node) {
-	for _, node := range nodes {
-		switch node.Type() {
-		case NodeTypeHeading:
-			headingHashes := strings.Repeat("#", node.(*Heading).Level)
-			sb.WriteString(headingHashes)
-			sb.WriteString(" ")
-			sb.WriteString(node.(*Heading).Text)
-			sb.WriteString("\n\n")
-		case NodeTypeParagraph:
-			sb.WriteString(node.(*Paragraph).Text)
-			sb.WriteString("\n\n")
-		case NodeTypeList:
-		case NodeTypeListElement:
-			if node.(*ListElement).Level != 1 {
-				for i := 1; i < node.(*ListElement).Level; i++ {
-					sb.WriteString("  ")
-				}
-			}
-			sb.WriteString("- ")
-			sb.WriteString(node.(*ListElement).Text)
-			sb.WriteString("\n")
-		case NodeTypeListEnd:
-			sb.WriteString("\n")
-		case NodeTypeTable:
-			formatTable(sb, node.(*Table))
-		case NodeTypeTableRow:
-		case NodeTypeTableElement:
+// result is the output of formatting a single file, done is closed once
+// it is complete.
+type result struct {
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// safeProcessFile is processFile, but turns a panic into an error wrapping
+// markdown.ErrInternal.
+func safeProcessFile(path string, in io.Reader, out io.Writer) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%s: %w: %v", path, markdown.ErrInternal, v)
 		}
+	}()
 
-		format(sb, node.Children())
-	}
+	return processFile(path, in, out)
 }
 
-func formatTable(sb *strings.Builder, table *Table) {
-	if len(table.rows) == 0 {
-		return
+// processFiles formats paths on up to n goroutines. The output and errors
+// are written in the order of paths, at most a few files per goroutine are
+// held back waiting for a slower file before them.
+func processFiles(paths []string, n int, out io.Writer) {
+	results := make([]*result, len(paths))
+	for i := range results {
+		results[i] = &result{done: make(chan struct{})}
 	}
 
-	rows := make([][]string, 0, len(table.rows))
-	for _, rowNode := range table.rows {
-		row := rowNode.(*TableRow)
-		elements := make([]string, 0, len(row.elements))
-		for _, elemNode := range row.elements {
-			elem := elemNode.(*TableElement)
-			elements = append(elements, elem.Text)
+	work := make(chan int)
+	window := make(chan struct{}, 4*n)
+	go func() {
+		for i := range paths {
+			window <- struct{}{}
+			work <- i
 		}
-		rows = append(rows, elements)
+		close(work)
+	}()
+
+	for range min(n, len(paths)) {
+		go func() {
+			for i := range work {
+				r := results[i]
+				r.err = safeProcessFile(paths[i], nil, &r.out)
+				close(r.done)
+			}
+		}()
 	}
 
-	maxCols := 0
-	for _, row := range rows {
-		if len(row) > maxCols {
-			maxCols = len(row)
+	for _, r := range results {
+		<-r.done
+		if _, err := out.Write(r.out.Bytes()); err != nil {
+			report(err)
 		}
-	}
-
-	if maxCols == 0 {
-		return
-	}
-
-	isSeparatorRow := func(row []string) bool {
-		if len(row) == 0 {
-			return false
+		if r.err != nil {
+			report(r.err)
 		}
+		<-window
+	}
+}
 
-		for _, cell := range row {
-			trimmed := strings.TrimSpace(cell)
-
-			hasNonDash := false
-			for _, r := range trimmed {
-				// check for dashes only
-				if r != '-' && r != ' ' && r != ':' {
-					hasNonDash = true
-					break
-				}
-			}
-
-			if hasNonDash {
-				return false
+// addStyleFlags defines a flag for every style option on flags, which
+// appends the option to flagOptions.
+func addStyleFlags(flags *flag.FlagSet) {
+	for _, o := range styleOptions {
+		key := o.name
+		set := func(value string) error {
+			scratch := markdown.DefaultFormatOptions()
+			if err := applyOption(&scratch, key, value); err != nil {
+				return err
 			}
+			flagOptions = append(flagOptions, option{key: key, value: value})
+			return nil
 		}
 
-		return true
+		if o.bool {
+			flags.BoolFunc(key, o.usage, set)
+		} else {
+			flags.Func(key, o.usage, set)
+		}
 	}
+}
 
-	// remove separator line
-	dataRows := make([][]string, 0)
-	for _, row := range rows {
-		if isSeparatorRow(row) {
-			continue
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ast" {
+		if err := astCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			report(err)
 		}
-
-		dataRows = append(dataRows, row)
+		os.Exit(exitCode)
 	}
 
-	// calculate column widths from data rows
-	colWidths := make([]int, maxCols)
-	for _, row := range dataRows {
-		for i := 0; i < len(row) && i < maxCols; i++ {
-			if len(row[i]) > colWidths[i] {
-				colWidths[i] = len(row[i])
+	addStyleFlags(flag.CommandLine)
+	globFlag := func(globs *[]string) func(string) error {
+		return func(value string) error {
+			if !validGlob(value) {
+				return fmt.Errorf("invalid glob %q", value)
 			}
+			*globs = append(*globs, value)
+			return nil
 		}
 	}
-
-	for rowIdx, row := range dataRows {
-		sb.WriteString("|")
-		for i := 0; i < maxCols; i++ {
-			var cellText string
-			if i < len(row) {
-				cellText = row[i]
-			}
-
-			padded := cellText + strings.Repeat(" ", colWidths[i]-len(cellText))
-			sb.WriteString(" ")
-			sb.WriteString(padded)
-			sb.WriteString(" |")
+	flag.Func("include", "only format Markdown files matching `glob` (repeatable)", globFlag(&includeGlobs))
+	flag.Func("exclude", "skip files and directories matching `glob` (repeatable)", globFlag(&excludeGlobs))
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			os.Exit(exitUsage)
 		}
-
-		sb.WriteString("\n")
-
-		if rowIdx == 0 && len(dataRows) > 1 {
-			sb.WriteString("|")
-			for i := 0; i < maxCols; i++ {
-				sb.WriteString(" ")
-				sb.WriteString(strings.Repeat("-", colWidths[i]))
-				sb.WriteString(" |")
-			}
-			sb.WriteString("\n")
+		if err := safeProcessFile(stdinName, os.Stdin, os.Stdout); err != nil {
+			report(err)
 		}
+		os.Exit(exitCode)
 	}
 
-	sb.WriteString("\n")
-}
+	if *jobs < 1 {
+		fmt.Fprintln(os.Stderr, "error: -j must be at least 1")
+		os.Exit(exitUsage)
+	}
 
-func main() {
-	in, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		panic(err)
+	paths := make([]string, 0)
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			report(err)
+		case info.IsDir():
+			files, err := findMarkdownFiles(path)
+			if err != nil {
+				report(err)
+			}
+			paths = append(paths, files...)
+		default:
+			paths = append(paths, path)
+		}
 	}
+	processFiles(paths, *jobs, os.Stdout)
 
-	parsed := Parse(string(in))
-	formatted := Fmt(parsed)
-	//dump(parsed.Children())
-	fmt.Println(formatted)
+	os.Exit(exitCode)
 }
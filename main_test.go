@@ -1,1160 +1,243 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"reflect"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
-)
-
-func dumpForTest(t *testing.T, want, got Node) {
-	t.Error("Parse result does not match expected output")
-	fmt.Println("=== want ===")
-	dump(want.Children())
-	fmt.Println("=== got ===")
-	dump(got.Children())
-}
-
-func printFmtForTest(t *testing.T, want, got string, parsed Node) {
-	t.Error("Want != got")
-	fmt.Println("=== want ===")
-	fmt.Println(want)
-	fmt.Println("=== got ===")
-	fmt.Println(got)
-	dump(parsed.Children())
-}
 
-func TestParseEmptyDocument(t *testing.T) {
-	input := ""
-	want := &Document{}
-	got := Parse(input)
+	"github.com/tuxikus/mdfmt/markdown"
+)
 
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+func TestProcessFileWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(path, []byte("# header\nsome text"), 0o600); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestParseLevelOneHeading(t *testing.T) {
-	input := "# Foo"
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Foo",
-			},
-		},
-	}
-	got := Parse(input)
+	defer func() { *write = false }()
+	*write = true
 
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if err := processFile(path, nil, nil); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestParseLevelTwoHeading(t *testing.T) {
-	input := "## Foo Bar"
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 2,
-				Text:  "Foo Bar",
-			},
-		},
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if want := "# header\n\nsome text\n"; string(got) != want {
+		t.Errorf("want %q, got %q", want, got)
 	}
-}
 
-func TestParseLevel9Heading(t *testing.T) {
-	input := "######### Foo Bar Baz"
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 9,
-				Text:  "Foo Bar Baz",
-			},
-		},
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("want permissions 0600, got %v", info.Mode().Perm())
 	}
-}
 
-func TestParseSingleLineParagraph(t *testing.T) {
-	input := "Foo"
-	want := &Document{
-		children: []Node{
-			&Paragraph{
-				Text: "Foo",
-			},
-		},
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if len(entries) != 1 {
+		t.Errorf("want only README.md, got %d files", len(entries))
 	}
 }
 
-func TestParseMultiLineParagraph(t *testing.T) {
-	input := `Foo Faz
-Bar Baz`
-	want := &Document{
-		children: []Node{
-			&Paragraph{
-				Text: "Foo Faz\nBar Baz",
-			},
-		},
+func TestProcessFileWriteSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "docs", "README.md")
+	if err := os.Mkdir(filepath.Dir(target), 0o755); err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if err := os.WriteFile(target, []byte("# header\nsome text"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestParseHeadingParagraph(t *testing.T) {
-	input := `# Heading
-Foo Faz
-Bar Baz`
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&Paragraph{
-				Text: "Foo Faz\nBar Baz",
-			},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	link := filepath.Join(dir, "README.md")
+	if err := os.Symlink(filepath.Join("docs", "README.md"), link); err != nil {
+		t.Skip(err)
 	}
-}
-
-func TestParseHeadingParagraphTailingNewLines(t *testing.T) {
-	input := `# Heading
-Foo Faz
-Bar Baz
 
+	defer func() { *write = false }()
+	*write = true
 
-`
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&Paragraph{
-				Text: "Foo Faz\nBar Baz",
-			},
-		},
+	if err := processFile(link, nil, nil); err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		t.Errorf("want %s, got %s", want, got)
-	}
-}
 
-func TestParseHeadingTwoParagraphs(t *testing.T) {
-	input := `# Heading
-Foo Faz
-Bar Baz
-
-Second paragraph`
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&Paragraph{
-				Text: "Foo Faz\nBar Baz",
-			},
-			&Paragraph{
-				Text: "Second paragraph",
-			},
-		},
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("want %s to stay a symbolic link, got mode %v", link, info.Mode())
 	}
-}
 
-func TestParseList(t *testing.T) {
-	input := "- Foo"
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "Foo",
-					},
-				},
-			},
-			&ListEnd{},
-		},
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if want := "# header\n\nsome text\n"; string(got) != want {
+		t.Errorf("want %q, got %q", want, got)
 	}
 }
 
-func TestParseListMoreHyphens(t *testing.T) {
-	input := "- Foo-Bar-Baz"
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "Foo-Bar-Baz",
-					},
-				},
-			},
-			&ListEnd{},
-		},
+func TestProcessFileListAndCheck(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "formatted.md")
+	empty := filepath.Join(dir, "empty.md")
+	unformatted := filepath.Join(dir, "unformatted.md")
+	if err := os.WriteFile(formatted, []byte("# header\n\nsome text\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestParseLongList(t *testing.T) {
-	input := `- one
-- two
-- three
-- four
-- five
-- six
-- seven
-- foo
-- bar
-- hello
-- world`
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "one",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "two",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "three",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "four",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "five",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "six",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "seven",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "bar",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "hello",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "world",
-					},
-				},
-			},
-			&ListEnd{},
-		},
+	if err := os.WriteFile(unformatted, []byte("# header\nsome text\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
 
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
+	defer func() { *list, *check, exitCode = false, false, 0 }()
+	*list, *check = true, true
 
-func TestParseMultiLevelList(t *testing.T) {
-	input := `- Foo
-  - Bar`
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "Foo",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "Bar",
-					},
-				},
-			},
-			&ListEnd{},
-		},
+	out := &strings.Builder{}
+	for _, path := range []string{formatted, empty, unformatted} {
+		if err := processFile(path, nil, out); err != nil {
+			t.Fatal(err)
+		}
 	}
-	got := Parse(input)
 
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if want := unformatted + "\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
 	}
-}
-
-func TestParseMultiLevelListLong(t *testing.T) {
-	input := `- Foo
-  - Bar
-    - Baz
-      - hello
-        - world
-          - test
-		    - long
-		      - list
-		        - here`
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "Foo",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "Bar",
-					},
-					&ListElement{
-						Level: 3,
-						Text:  "Baz",
-					},
-					&ListElement{
-						Level: 4,
-						Text:  "hello",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "world",
-					},
-					&ListElement{
-						Level: 6,
-						Text:  "test",
-					},
-					&ListElement{
-						Level: 7,
-						Text:  "long",
-					},
-					&ListElement{
-						Level: 8,
-						Text:  "list",
-					},
-					&ListElement{
-						Level: 9,
-						Text:  "here",
-					},
-				},
-			},
-			&ListEnd{},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseMultiLevelListLongComplex(t *testing.T) {
-	input := `- Foo
-  - Bar
-  - Bar2
-  - longer element
-    - Baz
-      - hello
-        - world
-        - foo
-        - foo
-        - foo
-        - foo
-          - test
-		    - long
-		      - list
-		        - here
-		          - even
-		            - more
-		              - elements
-		                - apple
-		                  - banana
-		                - coconut
-		              - test
-		              - abc
-		              - i
-		              - dont
-		              - know`
-	want := &Document{
-		children: []Node{
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "Foo",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "Bar",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "Bar2",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "longer element",
-					},
-					&ListElement{
-						Level: 3,
-						Text:  "Baz",
-					},
-					&ListElement{
-						Level: 4,
-						Text:  "hello",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "world",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 5,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 6,
-						Text:  "test",
-					},
-					&ListElement{
-						Level: 7,
-						Text:  "long",
-					},
-					&ListElement{
-						Level: 8,
-						Text:  "list",
-					},
-					&ListElement{
-						Level: 9,
-						Text:  "here",
-					},
-					&ListElement{
-						Level: 10,
-						Text:  "even",
-					},
-					&ListElement{
-						Level: 11,
-						Text:  "more",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "elements",
-					},
-					&ListElement{
-						Level: 13,
-						Text:  "apple",
-					},
-					&ListElement{
-						Level: 14,
-						Text:  "banana",
-					},
-					&ListElement{
-						Level: 13,
-						Text:  "coconut",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "test",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "abc",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "i",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "dont",
-					},
-					&ListElement{
-						Level: 12,
-						Text:  "know",
-					},
-				},
-			},
-			&ListEnd{},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseHeadingWithList(t *testing.T) {
-	input := `# Heading
-
-- element 1
-- element 2
-- element 3
-`
-
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "element 1",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 2",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 3",
-					},
-				},
-			},
-			&ListEnd{},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseHeadingWithListNoNL(t *testing.T) {
-	input := `# Heading
-- element 1
-- element 2
-- element 3
-`
-
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "element 1",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 2",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 3",
-					},
-				},
-			},
-			&ListEnd{},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseHeadingWithListComplex(t *testing.T) {
-	input := `# Heading
-
-- element 1
-- element 2
-- element 3
-
-## Next heading
-- element 1
-### The lvl 3 heading
-
-- foo
-  - bar
-    - baz
-`
-
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Heading",
-			},
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "element 1",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 2",
-					},
-					&ListElement{
-						Level: 1,
-						Text:  "element 3",
-					},
-				},
-			},
-			&ListEnd{},
-			&Heading{
-				Level: 2,
-				Text:  "Next heading",
-			},
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "element 1",
-					},
-				},
-			},
-			&ListEnd{},
-			&Heading{
-				Level: 3,
-				Text:  "The lvl 3 heading",
-			},
-			&List{
-				elements: []Node{
-					&ListElement{
-						Level: 1,
-						Text:  "foo",
-					},
-					&ListElement{
-						Level: 2,
-						Text:  "bar",
-					},
-					&ListElement{
-						Level: 3,
-						Text:  "baz",
-					},
-				},
-			},
-			&ListEnd{},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseTable(t *testing.T) {
-	input := "| Table |"
-	want := &Document{
-		children: []Node{
-			&Table{
-				rows: []Node{
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "Table",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
-
-func TestParseTableTwoByTwo(t *testing.T) {
-	input := `| one | two |
-| three | four |`
-	want := &Document{
-		children: []Node{
-			&Table{
-				rows: []Node{
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "one",
-							},
-							&TableElement{
-								Text: "two",
-							},
-						},
-					},
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "three",
-							},
-							&TableElement{
-								Text: "four",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if exitCode != 1 {
+		t.Errorf("want exit code 1, got %d", exitCode)
 	}
-}
 
-func TestParseTableTwoByTwoMissingValue(t *testing.T) {
-	input := `| one | |
-| three | four |`
-	want := &Document{
-		children: []Node{
-			&Table{
-				rows: []Node{
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "one",
-							},
-							&TableElement{
-								Text: "",
-							},
-						},
-					},
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "three",
-							},
-							&TableElement{
-								Text: "four",
-							},
-						},
-					},
-				},
-			},
-		},
+	got, err := os.ReadFile(unformatted)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
-
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	if string(got) != "# header\nsome text\n" {
+		t.Errorf("file was changed without -w: %q", got)
 	}
 }
 
-func TestParseTableTwoByTwoMissingValueMoreWhitespace(t *testing.T) {
-	input := `| one |       |
-| three | four |`
-	want := &Document{
-		children: []Node{
-			&Table{
-				rows: []Node{
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "one",
-							},
-							&TableElement{
-								Text: "",
-							},
-						},
-					},
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "three",
-							},
-							&TableElement{
-								Text: "four",
-							},
-						},
-					},
-				},
-			},
-		},
+func TestProcessFileDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(path, []byte("# header\nsome text\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
 
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
-	}
-}
+	defer func() { *doDiff = false }()
+	*doDiff = true
 
-func TestParseTableWithHeading(t *testing.T) {
-	input := `# Header
-| table header a | table header b |
-| ----- | ----- |
-| element a | element b |`
-	want := &Document{
-		children: []Node{
-			&Heading{
-				Level: 1,
-				Text:  "Header",
-			},
-			&Table{
-				rows: []Node{
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "table header a",
-							},
-							&TableElement{
-								Text: "table header b",
-							},
-						},
-					},
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "-----",
-							},
-							&TableElement{
-								Text: "-----",
-							},
-						},
-					},
-					&TableRow{
-						elements: []Node{
-							&TableElement{
-								Text: "element a",
-							},
-							&TableElement{
-								Text: "element b",
-							},
-						},
-					},
-				},
-			},
-		},
+	out := &strings.Builder{}
+	if err := processFile(path, nil, out); err != nil {
+		t.Fatal(err)
 	}
-	got := Parse(input)
 
-	if !reflect.DeepEqual(want, got) {
-		dumpForTest(t, want, got)
+	want := "--- " + path + ".orig\n+++ " + path + "\n@@ -1,2 +1,3 @@\n # header\n+\n some text\n"
+	if out.String() != want {
+		t.Errorf("want\n%s\ngot\n%s", want, out.String())
 	}
 }
 
-func TestFmtHeadingWithParagraph(t *testing.T) {
-	input := `# header
-some text`
-	want := `# header
-
-some text`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+func TestProcessFilesKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 0)
+	want := ""
+	for i := range 50 {
+		path := filepath.Join(dir, fmt.Sprintf("%02d.md", i))
+		src := fmt.Sprintf("# file %d\ntext\n", i)
+		if i%3 == 0 {
+			src = fmt.Sprintf("# file %d\n\ntext\n", i)
+		} else {
+			want += path + "\n"
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
 	}
-}
-
-func TestFmtHeadingWithTwoParagraphs(t *testing.T) {
-	input := `# header
-some text
-
 
+	defer func() { *list, *check, exitCode = false, false, 0 }()
+	*list, *check = true, true
 
+	out := &strings.Builder{}
+	processFiles(paths, 4, out)
 
-even more text`
-	want := `# header
-
-some text
-
-even more text`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	if out.String() != want {
+		t.Errorf("want\n%s\ngot\n%s", want, out.String())
 	}
-}
-
-func TestFmtHeadingWithLongParagraph(t *testing.T) {
-	input := `# Heading
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
-	want := `# Heading
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	if exitCode != 1 {
+		t.Errorf("want exit code 1, got %d", exitCode)
 	}
 }
 
-func TestFmtHeadingWithMoreLongParagraphs(t *testing.T) {
-	input := `# Heading
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
-
-
-
-
-
-
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
-
-
+func TestExitCodeFor(t *testing.T) {
+	_, statErr := os.Stat(filepath.Join(t.TempDir(), "missing.md"))
+	_, decodeErr := markdown.DecodeJSON(strings.NewReader(`{"type": "unknown"}`))
+	configErr := &usageError{errors.New(".mdfmt.toml:1: unknown key \"x\"")}
+	internalErr := fmt.Errorf("README.md: %w: boom", markdown.ErrInternal)
 
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
-	want := `# Heading
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{statErr, exitIO},
+		{decodeErr, 1},
+		{configErr, exitUsage},
+		{internalErr, exitInternal},
 	}
-}
-
-func TestFmtHeadingWithWrappedParagraph(t *testing.T) {
-	input := `# Heading
-Lorem ipsum dolor sit amet consectetur adipiscing elit.
-Quisque faucibus ex sapien vitae pellentesque sem
-placerat. In id cursus mi pretium tellus duis convallis.
-Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus
-fringilla lacus nec metus bibendum egestas. Iaculis massa
-nisl malesuada lacinia integer nunc posuere. Ut hendrerit
-semper vel class aptent taciti sociosqu. Ad litora torquent
-per conubia nostra inceptos himenaeos.`
-	want := `# Heading
-
-Lorem ipsum dolor sit amet consectetur adipiscing elit.
-Quisque faucibus ex sapien vitae pellentesque sem
-placerat. In id cursus mi pretium tellus duis convallis.
-Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus
-fringilla lacus nec metus bibendum egestas. Iaculis massa
-nisl malesuada lacinia integer nunc posuere. Ut hendrerit
-semper vel class aptent taciti sociosqu. Ad litora torquent
-per conubia nostra inceptos himenaeos.`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
 
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+		}
 	}
 }
 
-func TestFmtHeadingsWithParagraphs(t *testing.T) {
-	input := `# header
-some text
-
-more text
-
-## next heading
-with a paragraph`
-	want := `# header
-
-some text
-
-more text
-
-## next heading
-
-with a paragraph`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+func TestProcessFileInvalidUTF8(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(path, []byte("# header\n\xfe\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFmtTableSimple(t *testing.T) {
-	input := `| one | two |`
-	want := `| one | two |`
 
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	out := &strings.Builder{}
+	if err := processFile(path, nil, out); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFmtTableTwoRows(t *testing.T) {
-	input := `| one | two |
-| three | four |`
-	want := `| one   | two  |
-| ----- | ---- |
-| three | four |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	if want := "# header\n\n\xfe\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
 	}
 }
 
-func TestFmtTableWithSeparator(t *testing.T) {
-	input := `| header a | header b |
-| ----- | ----- |
-| element a | element b |`
-	want := `| header a  | header b  |
-| --------- | --------- |
-| element a | element b |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+func TestASTCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(path, []byte("# header\n\n*   a\n*   b\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFmtTableThreeColumns(t *testing.T) {
-	input := `| col1 | col2 | col3 |
-| val1 | val2 | val3 |
-| a | b | c |`
-	want := `| col1 | col2 | col3 |
-| ---- | ---- | ---- |
-| val1 | val2 | val3 |
-| a    | b    | c    |`
 
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	var tree strings.Builder
+	if err := astCommand([]string{path}, nil, &tree); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFmtTableUnevenColumns(t *testing.T) {
-	input := `| short | very long column | medium |
-| a | b | c |`
-	want := `| short | very long column | medium |
-| ----- | ---------------- | ------ |
-| a     | b                | c      |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
-	}
-}
-
-func TestFmtTableWithEmptyCells(t *testing.T) {
-	input := `| one | two | three |
-| a | | c |`
-	want := `| one | two | three |
-| --- | --- | ----- |
-| a   |     | c     |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	if !strings.Contains(tree.String(), `"type": "list_element"`) {
+		t.Errorf("want list elements in tree, got\n%s", tree.String())
 	}
-}
-
-func TestFmtTableWithHeading(t *testing.T) {
-	input := `# Header
-| col1 | col2 |
-| val1 | val2 |`
-	want := `# Header
-
-| col1 | col2 |
-| ---- | ---- |
-| val1 | val2 |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
 
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	var out strings.Builder
+	if err := astCommand([]string{"-decode"}, strings.NewReader(tree.String()), &out); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFmtTableMisalignedInput(t *testing.T) {
-	input := `|short|very long column|medium|
-|a|b|c|`
-	want := `| short | very long column | medium |
-| ----- | ---------------- | ------ |
-| a     | b                | c      |`
-
-	parsed := Parse(input)
-	got := Fmt(Parse(input))
-
-	if want != got {
-		printFmtForTest(t, want, got, parsed)
+	if want := "# header\n\n* a\n* b\n"; out.String() != want {
+		t.Errorf("want\n%s\ngot\n%s", want, out.String())
 	}
 }
@@ -0,0 +1,62 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInternal is wrapped by the errors returned when the parser or
+// formatter fails on an input it should have handled, it indicates a bug in
+// this package.
+var ErrInternal = errors.New("internal error")
+
+// Error is an error at a position in a Markdown document. Line and Column
+// start at 1 and count bytes like Position, they are 0 if the position is
+// unknown. File is left empty by this package for the caller to fill in.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		if pos != "" {
+			pos += ":"
+		}
+		pos += fmt.Sprint(e.Line)
+		if e.Column > 0 {
+			pos += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+
+	if pos == "" {
+		return e.Err.Error()
+	}
+	return pos + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// internalError converts a value recovered from a panic into an *Error at
+// line, which wraps ErrInternal. A value that already is an *Error, raised
+// by panicAt further down, is returned as is.
+func internalError(r any, line int) *Error {
+	if err, ok := r.(*Error); ok {
+		return err
+	}
+	return &Error{Line: line, Err: fmt.Errorf("%w: %v", ErrInternal, r)}
+}
+
+// panicAt is deferred by the loops of the parser and formatter, it panics
+// again with the recovered value converted to an internal error at *line,
+// the line being processed.
+func panicAt(line *int) {
+	if r := recover(); r != nil {
+		panic(internalError(r, *line))
+	}
+}
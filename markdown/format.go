@@ -0,0 +1,730 @@
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FormatOptions controls the style of the formatted document. The zero
+// value is not useful, start from DefaultFormatOptions.
+type FormatOptions struct {
+	// TabWidth is the number of spaces a tab indents a list element by
+	// when parsing.
+	TabWidth int
+	// ListIndent is the indentation of a nested list relative to its
+	// parent element. It is raised to the width of the parent's marker
+	// if that is wider, e.g. for "10. ".
+	ListIndent int
+
+	Numbering NumberingStyle
+	Bullet    BulletStyle
+	Wrap      ProseWrap
+	WrapWidth int // column ProseWrapAlways wraps text at
+
+	// TablePadding is the number of spaces between a pipe and the cell
+	// text.
+	TablePadding int
+	// BlankLineAfterHeading separates headings from the next block by a
+	// blank line.
+	BlankLineAfterHeading bool
+	// FinalNewline ends the output of Format with a newline, unless it is
+	// empty.
+	FinalNewline bool
+	// LineEnding is written at the end of every line, "\n" or "\r\n".
+	LineEnding string
+}
+
+// DefaultFormatOptions returns the options used by mdfmt without flags.
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		TabWidth:              4,
+		ListIndent:            2,
+		Numbering:             NumberingSequential,
+		Bullet:                BulletPreserve,
+		Wrap:                  ProseWrapPreserve,
+		WrapWidth:             80,
+		TablePadding:          1,
+		BlankLineAfterHeading: true,
+		FinalNewline:          true,
+		LineEnding:            "\n",
+	}
+}
+
+// Format writes node, usually a *Document, formatted according to opts to w.
+// A failure of the formatter itself is returned as an *Error wrapping
+// ErrInternal.
+func Format(w io.Writer, node Node, opts FormatOptions) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = internalError(v, 0)
+		}
+	}()
+
+	formatted := Fmt(node, opts)
+	// an empty document stays empty
+	if opts.FinalNewline && formatted != "" {
+		formatted += opts.LineEnding
+	}
+
+	_, err = io.WriteString(w, formatted)
+	return err
+}
+
+// Fmt returns node formatted according to opts, without a trailing newline.
+func Fmt(node Node, opts FormatOptions) string {
+	nodes := []Node{node}
+	if node.Type() == NodeTypeDocument {
+		nodes = node.Children()
+	}
+
+	sb := strings.Builder{}
+	format(&sb, nodes, 0, opts)
+	formatted := sb.String()
+
+	formatted = strings.TrimSuffix(strings.TrimSuffix(formatted, "\n\n"), "\n")
+	if opts.LineEnding != "" && opts.LineEnding != "\n" {
+		formatted = strings.ReplaceAll(formatted, "\n", opts.LineEnding)
+	}
+
+	return formatted
+}
+
+// format writes nodes to sb, each followed by a blank line. listDepth is the
+// number of lists the nodes are nested in.
+func format(sb *strings.Builder, nodes []Node, listDepth int, opts FormatOptions) {
+	// the marker of a bullet list directly before node
+	prevBullet := ""
+	line := 0
+	defer panicAt(&line)
+	for _, node := range nodes {
+		line = node.Span().Start.Line
+		avoid := prevBullet
+		prevBullet = ""
+
+		switch node.Type() {
+		case NodeTypeHeading:
+			headingHashes := strings.Repeat("#", node.(*Heading).Level)
+			sb.WriteString(headingHashes)
+			if text := node.(*Heading).Text; text != "" {
+				sb.WriteString(" ")
+				sb.WriteString(text)
+			}
+			sb.WriteString("\n")
+			if opts.BlankLineAfterHeading {
+				sb.WriteString("\n")
+			}
+		case NodeTypeParagraph:
+			sb.WriteString(strings.Join(wrapText(node.(*Paragraph).Text, opts.WrapWidth, opts.Wrap), "\n"))
+			sb.WriteString("\n\n")
+		case NodeTypeList:
+			prevBullet = formatList(sb, node.(*List), listDepth, avoid, opts)
+			continue
+		case NodeTypeListElement:
+		case NodeTypeTable:
+			formatTable(sb, node.(*Table), opts.TablePadding)
+		case NodeTypeTableRow:
+		case NodeTypeTableElement:
+		case NodeTypeCodeBlock:
+			codeBlock := node.(*CodeBlock)
+			fence := strings.Repeat(string(codeBlock.FenceChar), codeBlock.FenceLength)
+			sb.WriteString(fence)
+			sb.WriteString(codeBlock.Info)
+			sb.WriteString("\n")
+			sb.WriteString(codeBlock.Text)
+			sb.WriteString(fence)
+			sb.WriteString("\n\n")
+		case NodeTypeBlockquote:
+			formatBlockquote(sb, node.(*Blockquote), listDepth, opts)
+			continue
+		}
+
+		format(sb, node.Children(), listDepth, opts)
+	}
+}
+
+func formatBlockquote(sb *strings.Builder, blockquote *Blockquote, listDepth int, opts FormatOptions) {
+	// the quoted text is wrapped two columns earlier to make room for "> "
+	opts.WrapWidth -= 2
+
+	quoted := strings.Builder{}
+	format(&quoted, blockquote.children, listDepth, opts)
+
+	// nested blockquotes are already prefixed, which results in > >
+	for _, line := range strings.Split(strings.TrimRight(quoted.String(), "\n"), "\n") {
+		if line == "" {
+			sb.WriteString(">\n")
+			continue
+		}
+
+		sb.WriteString("> ")
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+}
+
+// ProseWrap selects how Format wraps the text of paragraphs and list elements.
+type ProseWrap int
+
+const (
+	// ProseWrapPreserve keeps the line breaks as written.
+	ProseWrapPreserve ProseWrap = iota
+	// ProseWrapAlways fills lines up to FormatOptions.WrapWidth.
+	ProseWrapAlways
+	// ProseWrapNever joins every paragraph into a single line.
+	ProseWrapNever
+)
+
+// ParseProseWrap returns the ProseWrap called name.
+func ParseProseWrap(name string) (ProseWrap, error) {
+	switch name {
+	case "preserve":
+		return ProseWrapPreserve, nil
+	case "always":
+		return ProseWrapAlways, nil
+	case "never":
+		return ProseWrapNever, nil
+	}
+
+	return 0, fmt.Errorf("unknown prose wrap %q", name)
+}
+
+// wrapText returns the lines of text re-flowed according to wrap. Hard line
+// breaks (two trailing spaces or a backslash) are kept.
+func wrapText(text string, width int, wrap ProseWrap) []string {
+	if wrap == ProseWrapPreserve {
+		return strings.Split(text, "\n")
+	}
+
+	wrapped := make([]string, 0)
+	segment := make([]string, 0)
+	lines := strings.Split(text, "\n")
+	// the underline of a setext heading stays on a line of its own
+	underline := ""
+	if n := len(lines); n > 1 && isSetextUnderline(lines[n-1]) {
+		underline, lines = lines[n-1], lines[:n-1]
+	}
+	for i, line := range lines {
+		segment = append(segment, line)
+
+		hardBreak := ""
+		if i < len(lines)-1 && strings.HasSuffix(line, "  ") {
+			hardBreak = "  "
+		} else if i < len(lines)-1 && !strings.HasSuffix(line, "\\") {
+			continue
+		}
+
+		segmentLines := fillWords(proseWords(strings.Join(segment, "\n")), width, wrap)
+		segmentLines[len(segmentLines)-1] += hardBreak
+		wrapped = append(wrapped, segmentLines...)
+		segment = segment[:0]
+	}
+	if underline != "" {
+		wrapped = append(wrapped, underline)
+	}
+
+	return wrapped
+}
+
+// fillWords joins words into lines no longer than width, or into a single
+// line for ProseWrapNever. Words longer than width get a line of their own.
+func fillWords(words []string, width int, wrap ProseWrap) []string {
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	lines := make([]string, 0)
+	line := words[0]
+	for _, word := range words[1:] {
+		// never start a line with something that would be parsed as a new
+		// block, like "-" or "1."
+		if wrap == ProseWrapAlways && !startsBlock(word) &&
+			displayWidth(line)+1+displayWidth(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+
+		line += " " + word
+	}
+
+	return append(lines, line)
+}
+
+// startsBlock reports whether a line starting with word would be parsed as
+// something other than paragraph text.
+func startsBlock(word string) bool {
+	if _, ok := parseListItem(word); ok {
+		return true
+	}
+	if _, _, _, _, ok := openingFence(word); ok {
+		return true
+	}
+
+	return strings.HasPrefix(word, "#") ||
+		strings.HasPrefix(word, "|") ||
+		quoteMarker(word) > 0 ||
+		isThematicBreak(word)
+}
+
+// proseWords splits text at white space into the words it can be wrapped
+// at. Code spans and links are never split, even if they contain spaces.
+func proseWords(text string) []string {
+	words := make([]string, 0)
+	word := strings.Builder{}
+
+	code := 0     // length of the backtick run opening the current code span
+	brackets := 0 // nesting of [ ] in link texts
+	parens := 0   // nesting of ( ) in link destinations
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == '`':
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			run := text[i : i+n]
+			if code == 0 && closingBackticks(text[i+n:], n) {
+				code = n
+			} else if code == n {
+				code = 0
+			}
+			word.WriteString(run)
+			i += n - 1
+			continue
+		case code > 0:
+		case c == '\\' && i+1 < len(text) && text[i+1] != '\n':
+			word.WriteByte(c)
+			word.WriteByte(text[i+1])
+			i++
+			continue
+		case c == '[' && strings.IndexByte(text[i:], ']') > 0:
+			brackets++
+		case c == ']' && brackets > 0:
+			brackets--
+			if i+1 < len(text) && text[i+1] == '(' && strings.IndexByte(text[i:], ')') > 0 {
+				parens++
+				word.WriteString("](")
+				i++
+				continue
+			}
+		case c == '(' && parens > 0:
+			parens++
+		case c == ')' && parens > 0:
+			parens--
+		}
+
+		if c == ' ' || c == '\t' || c == '\n' {
+			if code > 0 || brackets > 0 || parens > 0 {
+				word.WriteByte(' ')
+				continue
+			}
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+
+		word.WriteByte(c)
+	}
+
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+
+	return words
+}
+
+// closingBackticks reports whether text contains a run of exactly n
+// backticks.
+func closingBackticks(text string, n int) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '`' {
+			continue
+		}
+
+		run := 1
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			return true
+		}
+		i += run - 1
+	}
+
+	return false
+}
+
+// NumberingStyle selects how Format numbers ordered list elements.
+type NumberingStyle int
+
+const (
+	// NumberingSequential numbers elements start, start+1, start+2, ...
+	NumberingSequential NumberingStyle = iota
+	// NumberingOnes repeats the start number, usually 1, on every element.
+	NumberingOnes
+	// NumberingPreserve keeps the numbers as written.
+	NumberingPreserve
+)
+
+// ParseNumberingStyle returns the NumberingStyle called name.
+func ParseNumberingStyle(name string) (NumberingStyle, error) {
+	switch name {
+	case "sequential":
+		return NumberingSequential, nil
+	case "ones":
+		return NumberingOnes, nil
+	case "preserve":
+		return NumberingPreserve, nil
+	}
+
+	return 0, fmt.Errorf("unknown numbering style %q", name)
+}
+
+// BulletStyle selects the marker Format writes for bullet list elements. A
+// list directly following a bullet list never uses the same marker, as it
+// would continue that list: it uses * after - and - otherwise.
+type BulletStyle int
+
+const (
+	// BulletPreserve keeps the marker as written.
+	BulletPreserve BulletStyle = iota
+	// BulletHyphen uses - on every level.
+	BulletHyphen
+	// BulletAsterisk uses * on every level.
+	BulletAsterisk
+	// BulletPlus uses + on every level.
+	BulletPlus
+	// BulletAlternate cycles through -, * and + by nesting level.
+	BulletAlternate
+)
+
+// ParseBulletStyle returns the BulletStyle called name.
+func ParseBulletStyle(name string) (BulletStyle, error) {
+	switch name {
+	case "preserve":
+		return BulletPreserve, nil
+	case "-":
+		return BulletHyphen, nil
+	case "*":
+		return BulletAsterisk, nil
+	case "+":
+		return BulletPlus, nil
+	case "alternate":
+		return BulletAlternate, nil
+	}
+
+	return 0, fmt.Errorf("unknown bullet style %q", name)
+}
+
+// bulletMarker returns the marker written for the bullet element elem of a
+// list nested in listDepth other lists.
+func bulletMarker(elem *ListElement, listDepth int, bullet BulletStyle) string {
+	switch bullet {
+	case BulletHyphen:
+		return "-"
+	case BulletAsterisk:
+		return "*"
+	case BulletPlus:
+		return "+"
+	case BulletAlternate:
+		return string("-*+"[listDepth%3])
+	}
+
+	if elem.Marker == 0 {
+		return "-"
+	}
+
+	return string(elem.Marker)
+}
+
+// listMarkers returns the markers of the elements of list, bullet elements
+// do not use the marker avoid.
+func listMarkers(list *List, listDepth int, avoid string, opts FormatOptions) []string {
+	markers := make([]string, 0, len(list.elements))
+	if len(list.elements) == 0 {
+		return markers
+	}
+	first := list.elements[0].(*ListElement)
+
+	for _, elemNode := range list.elements {
+		elem := elemNode.(*ListElement)
+		if !elem.Ordered {
+			marker := bulletMarker(elem, listDepth, opts.Bullet)
+			if marker == avoid {
+				marker = "*"
+				if avoid == "*" {
+					marker = "-"
+				}
+			}
+			markers = append(markers, marker)
+			continue
+		}
+
+		number := elem.Number
+		switch opts.Numbering {
+		case NumberingSequential:
+			number = first.Number + len(markers)
+		case NumberingOnes:
+			number = first.Number
+		}
+		markers = append(markers, strconv.Itoa(number)+string(elem.Delimiter))
+	}
+
+	return markers
+}
+
+// formatList writes list to sb without using the bullet marker avoid of a
+// list directly before it, and returns its own bullet marker, if any.
+func formatList(sb *strings.Builder, list *List, listDepth int, avoid string, opts FormatOptions) string {
+	markers := listMarkers(list, listDepth, avoid, opts)
+	if len(markers) == 0 {
+		return ""
+	}
+
+	// the texts of all elements line up after the widest marker
+	width := 0
+	for _, marker := range markers {
+		width = max(width, len(marker)+1)
+	}
+	// nested lists are indented at least up to the text to stay nested and
+	// less than four more to not become an indented code block
+	nestedIndent := min(max(opts.ListIndent, width), width+3)
+
+	// a blank line between two blocks of an element makes the whole list loose
+	loose := list.Loose
+	for _, elemNode := range list.elements {
+		children := elemNode.Children()
+		for j := 1; j < len(children); j++ {
+			loose = loose || needsBlankLine(children[j-1], children[j])
+		}
+	}
+
+	for i, elemNode := range list.elements {
+		elem := elemNode.(*ListElement)
+		if i > 0 && loose {
+			sb.WriteString("\n")
+		}
+		if len(elem.children) == 0 {
+			sb.WriteString(markers[i])
+			sb.WriteString("\n")
+			continue
+		}
+
+		prevBullet := ""
+		for j, child := range elem.children {
+			indent := width
+			if j > 0 && child.Type() == NodeTypeList {
+				indent = nestedIndent
+			}
+			if j > 0 && loose {
+				sb.WriteString("\n")
+			}
+
+			childOpts := opts
+			childOpts.WrapWidth -= indent
+			block := strings.Builder{}
+			avoid := prevBullet
+			prevBullet = ""
+			if nested, ok := child.(*List); ok {
+				prevBullet = formatList(&block, nested, listDepth+1, avoid, childOpts)
+			} else {
+				format(&block, []Node{child}, listDepth+1, childOpts)
+			}
+
+			for k, line := range strings.Split(strings.TrimRight(block.String(), "\n"), "\n") {
+				switch {
+				case j == 0 && k == 0 && strings.HasPrefix(line, " "):
+					// the indentation would be lost after the marker
+					sb.WriteString(markers[i])
+					sb.WriteString("\n")
+					sb.WriteString(strings.Repeat(" ", indent))
+				case j == 0 && k == 0:
+					sb.WriteString(markers[i])
+					sb.WriteString(strings.Repeat(" ", width-len(markers[i])))
+				case line != "":
+					sb.WriteString(strings.Repeat(" ", indent))
+				}
+				sb.WriteString(line)
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	sb.WriteString("\n")
+
+	if list.elements[0].(*ListElement).Ordered {
+		return ""
+	}
+	return markers[0]
+}
+
+// needsBlankLine reports whether the consecutive blocks prev and next of a
+// list element must be separated by a blank line, to not join two paragraphs
+// or continue the last element of a nested list with the next block.
+func needsBlankLine(prev, next Node) bool {
+	switch prev.Type() {
+	case NodeTypeParagraph:
+		return next.Type() == NodeTypeParagraph
+	case NodeTypeList:
+		return next.Type() != NodeTypeList
+	}
+
+	return false
+}
+
+// isDelimiterRow reports whether cells is a table delimiter row made of
+// dashes and alignment colons only, with at least one dash.
+func isDelimiterRow(cells []string) bool {
+	if !strings.Contains(strings.Join(cells, ""), "-") {
+		return false
+	}
+
+	for _, cell := range cells {
+		trimmed := strings.TrimSpace(cell)
+
+		for _, r := range trimmed {
+			// check for dashes only
+			if r != '-' && r != ' ' && r != ':' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// cellAlignment returns the Alignment of a delimiter row cell.
+func cellAlignment(cell string) Alignment {
+	cell = strings.TrimSpace(cell)
+	left := strings.HasPrefix(cell, ":")
+	right := len(cell) > 1 && strings.HasSuffix(cell, ":")
+
+	switch {
+	case left && right:
+		return AlignCenter
+	case left:
+		return AlignLeft
+	case right:
+		return AlignRight
+	}
+
+	return AlignNone
+}
+
+func formatTable(sb *strings.Builder, table *Table, padding int) {
+	if len(table.rows) == 0 {
+		return
+	}
+
+	rows := make([][]string, 0, len(table.rows))
+	for _, rowNode := range table.rows {
+		row := rowNode.(*TableRow)
+		elements := make([]string, 0, len(row.elements))
+		for _, elemNode := range row.elements {
+			elem := elemNode.(*TableElement)
+			elements = append(elements, elem.Text)
+		}
+		rows = append(rows, elements)
+	}
+
+	maxCols := 0
+	for _, row := range rows {
+		if len(row) > maxCols {
+			maxCols = len(row)
+		}
+	}
+
+	if maxCols == 0 {
+		return
+	}
+
+	// remove the delimiter row, it is written from the alignments
+	dataRows := make([][]string, 0)
+	for i, row := range rows {
+		if i > 0 && i == table.delimiterRow {
+			continue
+		}
+
+		dataRows = append(dataRows, row)
+	}
+
+	alignments := make([]Alignment, maxCols)
+	copy(alignments, table.Alignments)
+
+	// calculate column widths from data rows, leaving room for the colons of
+	// the delimiter row and at least one dash
+	colWidths := make([]int, maxCols)
+	for i, alignment := range alignments {
+		colWidths[i] = 1
+		switch alignment {
+		case AlignLeft, AlignRight:
+			colWidths[i] = 2
+		case AlignCenter:
+			colWidths[i] = 3
+		}
+	}
+	for _, row := range dataRows {
+		for i := 0; i < len(row) && i < maxCols; i++ {
+			if width := displayWidth(row[i]); width > colWidths[i] {
+				colWidths[i] = width
+			}
+		}
+	}
+
+	space := strings.Repeat(" ", padding)
+	for rowIdx, row := range dataRows {
+		sb.WriteString("|")
+		for i := 0; i < maxCols; i++ {
+			var cellText string
+			if i < len(row) {
+				cellText = row[i]
+			}
+
+			fill := colWidths[i] - displayWidth(cellText)
+			var padded string
+			switch alignments[i] {
+			case AlignRight:
+				padded = strings.Repeat(" ", fill) + cellText
+			case AlignCenter:
+				padded = strings.Repeat(" ", fill/2) + cellText + strings.Repeat(" ", fill-fill/2)
+			default:
+				padded = cellText + strings.Repeat(" ", fill)
+			}
+			sb.WriteString(space)
+			sb.WriteString(padded)
+			sb.WriteString(space + "|")
+		}
+
+		sb.WriteString("\n")
+
+		if rowIdx == 0 && (len(dataRows) > 1 || table.Alignments != nil) {
+			sb.WriteString("|")
+			for i := 0; i < maxCols; i++ {
+				sb.WriteString(space)
+				switch alignments[i] {
+				case AlignLeft:
+					sb.WriteString(":" + strings.Repeat("-", colWidths[i]-1))
+				case AlignCenter:
+					sb.WriteString(":" + strings.Repeat("-", colWidths[i]-2) + ":")
+				case AlignRight:
+					sb.WriteString(strings.Repeat("-", colWidths[i]-1) + ":")
+				default:
+					sb.WriteString(strings.Repeat("-", colWidths[i]))
+				}
+				sb.WriteString(space + "|")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString("\n")
+}
@@ -0,0 +1,933 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func printFmtForTest(t *testing.T, want, got string, parsed Node) {
+	t.Error("Want != got")
+	fmt.Println("=== want ===")
+	fmt.Println(want)
+	fmt.Println("=== got ===")
+	fmt.Println(got)
+	dump(parsed.Children())
+}
+
+func TestFmtHeadingWithParagraph(t *testing.T) {
+	input := `# header
+some text`
+	want := `# header
+
+some text`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHashLines(t *testing.T) {
+	input := "#\nThis was fixed in pull request\n#123 last week.\n##\tTabbed"
+	want := "#\n\nThis was fixed in pull request\n#123 last week.\n\n## Tabbed"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHeadingWithTwoParagraphs(t *testing.T) {
+	input := `# header
+some text
+
+
+
+
+even more text`
+	want := `# header
+
+some text
+
+even more text`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHeadingWithLongParagraph(t *testing.T) {
+	input := `# Heading
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
+	want := `# Heading
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHeadingWithMoreLongParagraphs(t *testing.T) {
+	input := `# Heading
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
+
+
+
+
+
+
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
+
+
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
+	want := `# Heading
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit. Quisque faucibus ex sapien vitae pellentesque sem placerat. In id cursus mi pretium tellus duis convallis. Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus nec metus bibendum egestas. Iaculis massa nisl malesuada lacinia integer nunc posuere. Ut hendrerit semper vel class aptent taciti sociosqu. Ad litora torquent per conubia nostra inceptos himenaeos.`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHeadingWithWrappedParagraph(t *testing.T) {
+	input := `# Heading
+Lorem ipsum dolor sit amet consectetur adipiscing elit.
+Quisque faucibus ex sapien vitae pellentesque sem
+placerat. In id cursus mi pretium tellus duis convallis.
+Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus
+fringilla lacus nec metus bibendum egestas. Iaculis massa
+nisl malesuada lacinia integer nunc posuere. Ut hendrerit
+semper vel class aptent taciti sociosqu. Ad litora torquent
+per conubia nostra inceptos himenaeos.`
+	want := `# Heading
+
+Lorem ipsum dolor sit amet consectetur adipiscing elit.
+Quisque faucibus ex sapien vitae pellentesque sem
+placerat. In id cursus mi pretium tellus duis convallis.
+Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus
+fringilla lacus nec metus bibendum egestas. Iaculis massa
+nisl malesuada lacinia integer nunc posuere. Ut hendrerit
+semper vel class aptent taciti sociosqu. Ad litora torquent
+per conubia nostra inceptos himenaeos.`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtHeadingsWithParagraphs(t *testing.T) {
+	input := `# header
+some text
+
+more text
+
+## next heading
+with a paragraph`
+	want := `# header
+
+some text
+
+more text
+
+## next heading
+
+with a paragraph`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableSimple(t *testing.T) {
+	input := `| one | two |`
+	want := `| one | two |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableTwoRows(t *testing.T) {
+	input := `| one | two |
+| three | four |`
+	want := `| one   | two  |
+| ----- | ---- |
+| three | four |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableWithSeparator(t *testing.T) {
+	input := `| header a | header b |
+| ----- | ----- |
+| element a | element b |`
+	want := `| header a  | header b  |
+| --------- | --------- |
+| element a | element b |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableThreeColumns(t *testing.T) {
+	input := `| col1 | col2 | col3 |
+| val1 | val2 | val3 |
+| a | b | c |`
+	want := `| col1 | col2 | col3 |
+| ---- | ---- | ---- |
+| val1 | val2 | val3 |
+| a    | b    | c    |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableUnevenColumns(t *testing.T) {
+	input := `| short | very long column | medium |
+| a | b | c |`
+	want := `| short | very long column | medium |
+| ----- | ---------------- | ------ |
+| a     | b                | c      |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableWithEmptyCells(t *testing.T) {
+	input := `| one | two | three |
+| a | | c |`
+	want := `| one | two | three |
+| --- | --- | ----- |
+| a   |     | c     |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableKeepsRowsLikeDelimiters(t *testing.T) {
+	input := "| a | b |\n|---|:-:|\n| 1 | 2 |\n|   |  |\n| - | : |\n\nbefore\n\n| - |\n\nafter"
+	want := "| a |  b  |\n| - | :-: |\n| 1 |  2  |\n|   |     |\n| - |  :  |\n\nbefore\n\n| - |\n\nafter"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+
+	reparsed := parse(got, DefaultFormatOptions())
+	if again := Fmt(reparsed, DefaultFormatOptions()); again != got {
+		printFmtForTest(t, got, again, reparsed)
+	}
+}
+
+func TestFmtTableWithHeading(t *testing.T) {
+	input := `# Header
+| col1 | col2 |
+| val1 | val2 |`
+	want := `# Header
+
+| col1 | col2 |
+| ---- | ---- |
+| val1 | val2 |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableMisalignedInput(t *testing.T) {
+	input := `|short|very long column|medium|
+|a|b|c|`
+	want := `| short | very long column | medium |
+| ----- | ---------------- | ------ |
+| a     | b                | c      |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtCodeBlockVerbatim(t *testing.T) {
+	input := "# Installation\n```shell\n  $ go install github.com/tuxikus/mdfmt@latest\n\n  # or clone locally\n| a |   b |\n- x\n```\ntext"
+	want := "# Installation\n\n```shell\n  $ go install github.com/tuxikus/mdfmt@latest\n\n  # or clone locally\n| a |   b |\n- x\n```\n\ntext"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtCodeBlockUnclosed(t *testing.T) {
+	input := "```go\nfunc main() {}\n"
+	want := "```go\nfunc main() {}\n```"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtOrderedListNumbering(t *testing.T) {
+	input := `3. three
+3. three
+7. seven
+   1. nested
+   1. nested`
+	tests := []struct {
+		numbering NumberingStyle
+		want      string
+	}{
+		{NumberingSequential, "3. three\n4. three\n5. seven\n   1. nested\n   2. nested"},
+		{NumberingOnes, "3. three\n3. three\n3. seven\n   1. nested\n   1. nested"},
+		{NumberingPreserve, "3. three\n3. three\n7. seven\n   1. nested\n   1. nested"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultFormatOptions()
+		opts.Numbering = tt.numbering
+
+		parsed := parse(input, DefaultFormatOptions())
+		got := Fmt(parsed, opts)
+
+		if tt.want != got {
+			printFmtForTest(t, tt.want, got, parsed)
+		}
+	}
+}
+
+func TestFmtOrderedListAlignsTenItems(t *testing.T) {
+	input := `1. a
+1. b
+1. c
+1. d
+1. e
+1. f
+1. g
+1. h
+1. i
+1. j
+   - nested`
+	want := `1.  a
+2.  b
+3.  c
+4.  d
+5.  e
+6.  f
+7.  g
+8.  h
+9.  i
+10. j
+    - nested`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtBulletStyles(t *testing.T) {
+	input := `* one
+  + two
+    - three
+      * four
+* five`
+	tests := []struct {
+		bullet BulletStyle
+		want   string
+	}{
+		{BulletPreserve, "* one\n  + two\n    - three\n      * four\n* five"},
+		{BulletHyphen, "- one\n  - two\n    - three\n      - four\n- five"},
+		{BulletAsterisk, "* one\n  * two\n    * three\n      * four\n* five"},
+		{BulletPlus, "+ one\n  + two\n    + three\n      + four\n+ five"},
+		{BulletAlternate, "- one\n  * two\n    + three\n      - four\n- five"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultFormatOptions()
+		opts.Bullet = tt.bullet
+
+		parsed := parse(input, DefaultFormatOptions())
+		got := Fmt(parsed, opts)
+
+		if tt.want != got {
+			printFmtForTest(t, tt.want, got, parsed)
+		}
+	}
+}
+
+func TestFmtBlockquote(t *testing.T) {
+	input := `>## Quote
+>some text
+>
+>* item
+>>| a | bb |
+>>| ccc | d |
+>>
+>>` + "```" + `
+>>   code
+>>` + "```"
+	want := `> ## Quote
+>
+> some text
+>
+> * item
+>
+> > | a   | bb |
+> > | --- | -- |
+> > | ccc | d  |
+> >
+> > ` + "```" + `
+> >   code
+> > ` + "```"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtWrapAlways(t *testing.T) {
+	input := `Lorem ipsum dolor sit amet, ` + "`consectetur adipiscing`" + ` elit. Quisque
+faucibus ex sapien [vitae pellentesque](https://example.com) sem - placerat.
+In id cursus mi pretium tellus duis convallis.
+
+- Tempus leo eu aenean sed diam urna tempor. Pulvinar vivamus fringilla lacus.`
+	want := `Lorem ipsum dolor sit amet,
+` + "`consectetur adipiscing`" + ` elit. Quisque
+faucibus ex sapien
+[vitae pellentesque](https://example.com)
+sem - placerat. In id cursus mi pretium
+tellus duis convallis.
+
+- Tempus leo eu aenean sed diam urna
+  tempor. Pulvinar vivamus fringilla
+  lacus.`
+
+	opts := DefaultFormatOptions()
+	opts.Wrap, opts.WrapWidth = ProseWrapAlways, 40
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+
+	if again := Fmt(parse(got, DefaultFormatOptions()), opts); again != got {
+		printFmtForTest(t, got, again, parse(got, DefaultFormatOptions()))
+	}
+}
+
+func TestFmtWrapNever(t *testing.T) {
+	input := `Lorem ipsum dolor
+sit amet  
+consectetur\
+adipiscing elit.
+
+- Tempus leo eu
+  aenean sed diam`
+	want := `Lorem ipsum dolor sit amet  
+consectetur\
+adipiscing elit.
+
+- Tempus leo eu aenean sed diam`
+
+	opts := DefaultFormatOptions()
+	opts.Wrap = ProseWrapNever
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtWrapDoesNotStartBlocks(t *testing.T) {
+	input := `aaaa - bbbb 1. cccc # dddd > eeee`
+	want := `aaaa -
+bbbb 1.
+cccc #
+dddd >
+eeee`
+
+	opts := DefaultFormatOptions()
+	opts.Wrap, opts.WrapWidth = ProseWrapAlways, 4
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableAlignments(t *testing.T) {
+	input := `| name | count | status | total |
+|:-|:-:|-:|---|
+| a | 1 | ok | 12 |
+| bbbbbbb | 100 | failed | 3 |`
+	want := `| name    | count | status | total |
+| :------ | :---: | -----: | ----- |
+| a       |   1   |     ok | 12    |
+| bbbbbbb |  100  | failed | 3     |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableAlignmentsNarrowColumns(t *testing.T) {
+	input := `| a | b | c |
+|:-:|-:|:-|`
+	want := `|  a  |  b | c  |
+| :-: | -: | :- |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableUnicode(t *testing.T) {
+	input := `| Wort | 意味 |
+| --- | --- |
+| Größe | 大きさ |
+| a | 👍🏽 |`
+	want := `| Wort  | 意味   |
+| ----- | ------ |
+| Größe | 大きさ |
+| a     | 👍🏽     |`
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtTableEscapedPipes(t *testing.T) {
+	input := "| command | description |\n| --- | --- |\n| `ls \\| wc -l` | count \\| files |\n| `ps | grep go` | `|` |"
+	want := "| command        | description    |\n| -------------- | -------------- |\n| `ls \\| wc -l`  | count \\| files |\n| `ps | grep go` | `|`            |"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	doc, err := Parse(strings.NewReader("# header\nsome text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sb := strings.Builder{}
+	if err := Format(&sb, doc, DefaultFormatOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "# header\n\nsome text\n"; sb.String() != want {
+		t.Errorf("want %q, got %q", want, sb.String())
+	}
+}
+
+func TestFmtOptions(t *testing.T) {
+	input := `# Heading
+text
+
+- one
+	- two
+
+| a | b |
+| - | - |
+| c | d |`
+	want := `# Heading
+text
+
+- one
+    - two
+
+|a|b|
+|-|-|
+|c|d|`
+
+	opts := DefaultFormatOptions()
+	opts.TabWidth = 2
+	opts.ListIndent = 4
+	opts.TablePadding = 0
+	opts.BlankLineAfterHeading = false
+
+	parsed := parse(input, opts)
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtListIndentKeepsNesting(t *testing.T) {
+	input := `1. one
+   - two
+     - three`
+	tests := []struct {
+		listIndent int
+		want       string
+	}{
+		{0, "1. one\n   - two\n     - three"},
+		{4, "1. one\n    - two\n        - three"},
+		{9, "1. one\n      - two\n           - three"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultFormatOptions()
+		opts.ListIndent = tt.listIndent
+
+		parsed := parse(input, opts)
+		got := Fmt(parsed, opts)
+
+		if tt.want != got {
+			printFmtForTest(t, tt.want, got, parsed)
+		}
+	}
+}
+
+func TestFormatFinalNewline(t *testing.T) {
+	doc := parse("text", DefaultFormatOptions())
+	opts := DefaultFormatOptions()
+	opts.FinalNewline = false
+
+	sb := strings.Builder{}
+	if err := Format(&sb, doc, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if sb.String() != "text" {
+		t.Errorf("want %q, got %q", "text", sb.String())
+	}
+}
+
+func TestFormatEmptyDocument(t *testing.T) {
+	for _, input := range []string{"", "\n", " \n\n\t\n"} {
+		doc, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sb := strings.Builder{}
+		if err := Format(&sb, doc, DefaultFormatOptions()); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != "" {
+			t.Errorf("%q: want no output, got %q", input, sb.String())
+		}
+	}
+}
+
+func TestFormatCRLF(t *testing.T) {
+	doc, err := Parse(strings.NewReader("# header\r\nsome text\r\n\r\n```\r\ncode\r\n```\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatOptions()
+	opts.LineEnding = "\r\n"
+
+	sb := strings.Builder{}
+	if err := Format(&sb, doc, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "# header\r\n\r\nsome text\r\n\r\n```\r\ncode\r\n```\r\n"; sb.String() != want {
+		t.Errorf("want %q, got %q", want, sb.String())
+	}
+}
+
+// brokenNode claims to be a heading without being one.
+type brokenNode struct{ span Span }
+
+func (brokenNode) Type() NodeType   { return NodeTypeHeading }
+func (brokenNode) Children() []Node { return nil }
+func (n brokenNode) Span() Span     { return n.span }
+
+func TestFormatInternalError(t *testing.T) {
+	broken := brokenNode{span: Span{Start: Position{Line: 3, Column: 1, Offset: 7}}}
+	doc := &Document{children: []Node{&Paragraph{Text: "text"}, broken}}
+
+	err := Format(&strings.Builder{}, doc, DefaultFormatOptions())
+	if !errors.Is(err, ErrInternal) {
+		t.Errorf("want ErrInternal, got %v", err)
+	}
+
+	var mdErr *Error
+	if !errors.As(err, &mdErr) || mdErr.Line != 3 {
+		t.Errorf("want an *Error at line 3, got %v", err)
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, s := range []string{"", "|", "| a |\n|-|", "- a\n  - b\n1. c", "> - a\n>", "```\nx", "# h\ntext `b`", "\t-\t1)"} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		opts := DefaultFormatOptions()
+		opts.Wrap = ProseWrapAlways
+		opts.WrapWidth = 10
+
+		doc, err := ParseWithOptions(strings.NewReader(in), opts)
+		if err != nil {
+			return
+		}
+		if err := Format(&strings.Builder{}, doc, opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFmtListElementChildren(t *testing.T) {
+	input := "10. one\n    ```go\n    x := 1\n    ```\n    - nested\n      > quoted\n11. two"
+	want := "10. one\n    ```go\n    x := 1\n    ```\n    - nested\n      > quoted\n11. two"
+
+	opts := DefaultFormatOptions()
+	parsed := parse(input, opts)
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtListParagraphsAndLazyLines(t *testing.T) {
+	input := `- Fixed a crash when the configuration file
+is empty.
+- Added -j.
+
+  It formats files in parallel.
+
+    ` + "```sh\n    mdfmt -j 4 docs/\n    ```" + `
+- Nested:
+  * one
+  continued lazily
+  * two`
+	want := `- Fixed a crash when the configuration file
+  is empty.
+
+- Added -j.
+
+  It formats files in parallel.
+
+  ` + "```sh\n  mdfmt -j 4 docs/\n  ```" + `
+
+- Nested:
+
+  * one
+    continued lazily
+  * two`
+
+	opts := DefaultFormatOptions()
+	parsed := parse(input, opts)
+	got := Fmt(parsed, opts)
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtListBlocksNeedingBlankLines(t *testing.T) {
+	doc := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						Marker: '-',
+						children: []Node{
+							&Paragraph{Text: "one"},
+							&Paragraph{Text: "two"},
+							&List{
+								Marker:   '-',
+								elements: []Node{&ListElement{Marker: '-', children: []Node{&Paragraph{Text: "nested"}}}},
+							},
+							&Paragraph{Text: "three"},
+						},
+					},
+				},
+			},
+		},
+	}
+	want := "- one\n\n  two\n\n  - nested\n\n  three"
+
+	if got := Fmt(doc, DefaultFormatOptions()); want != got {
+		printFmtForTest(t, want, got, doc)
+	}
+}
+
+func TestFmtListNestedBeyondContentIndent(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"- a\n    - b", "* a\n  * b"},
+		{"- a\n\t- b", "* a\n  * b"},
+		{"1. step\n    - detail", "1. step\n   * detail"},
+		{"1. step\n\t- detail", "1. step\n   * detail"},
+	}
+
+	opts := DefaultFormatOptions()
+	opts.Wrap = ProseWrapAlways
+	opts.Bullet = BulletAsterisk
+	for _, test := range tests {
+		parsed := parse(test.input, opts)
+		if got := Fmt(parsed, opts); test.want != got {
+			printFmtForTest(t, test.want, got, parsed)
+		}
+	}
+}
+
+func TestFmtWrapStopsAtBlocks(t *testing.T) {
+	input := "Some text\n# Heading\nmore text\n| a | b |\n| - | - |"
+	want := "Some text\n\n# Heading\n\nmore text\n\n| a | b |\n| - | - |"
+
+	for _, wrap := range []ProseWrap{ProseWrapAlways, ProseWrapNever} {
+		opts := DefaultFormatOptions()
+		opts.Wrap = wrap
+
+		parsed := parse(input, opts)
+		if got := Fmt(parsed, opts); want != got {
+			printFmtForTest(t, want, got, parsed)
+		}
+	}
+}
+
+func TestFmtBlockquoteLazyContinuation(t *testing.T) {
+	input := "> > deep\n> back\n\n> quote\nlazy\n***"
+	want := "> > deep\n> > back\n\n> quote\n> lazy\n\n***"
+
+	parsed := parse(input, DefaultFormatOptions())
+	got := Fmt(parsed, DefaultFormatOptions())
+
+	if want != got {
+		printFmtForTest(t, want, got, parsed)
+	}
+}
+
+func TestFmtThematicBreaks(t *testing.T) {
+	input := "para\n\n---\n\nA long title\n---\n- a\n\n* * *\n\n- b"
+	want := "para\n\n---\n\nA long title\n---\n\n- a\n\n* * *\n\n- b"
+
+	for _, wrap := range []ProseWrap{ProseWrapPreserve, ProseWrapAlways, ProseWrapNever} {
+		opts := DefaultFormatOptions()
+		opts.Bullet = BulletHyphen
+		opts.Wrap = wrap
+
+		parsed := parse(input, opts)
+		if got := Fmt(parsed, opts); want != got {
+			printFmtForTest(t, want, got, parsed)
+		}
+	}
+}
+
+func TestFmtBulletKeepsAdjacentListsApart(t *testing.T) {
+	input := "- a\n- b\n* c\n* d\n+ e\n\n- x\n  - nested\n  + sibling"
+
+	countLists := func(node Node) int {
+		n := 0
+		Inspect(node, func(node Node) bool {
+			if node != nil && node.Type() == NodeTypeList {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+
+	for _, bullet := range []BulletStyle{BulletPreserve, BulletHyphen, BulletAsterisk, BulletPlus, BulletAlternate} {
+		opts := DefaultFormatOptions()
+		opts.Bullet = bullet
+
+		parsed := parse(input, opts)
+		once := Fmt(parsed, opts)
+		reparsed := parse(once, opts)
+		if twice := Fmt(reparsed, opts); once != twice {
+			printFmtForTest(t, once, twice, reparsed)
+		}
+		if want, got := countLists(parsed), countLists(reparsed); want != got {
+			t.Errorf("bullet style %d: want %d lists, got %d in\n%s", bullet, want, got, once)
+		}
+	}
+}
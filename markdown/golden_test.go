@@ -0,0 +1,94 @@
+package markdown
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the formatter output")
+
+// formatForGolden parses and formats src with the default options.
+func formatForGolden(t *testing.T, src []byte) []byte {
+	t.Helper()
+
+	doc, err := Parse(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Format(&buf, doc, DefaultFormatOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// firstDifference returns the first line at which want and got differ.
+func firstDifference(want, got []byte) (line int, wantLine, gotLine string) {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; ; i++ {
+		if i >= len(wantLines) || i >= len(gotLines) || wantLines[i] != gotLines[i] {
+			wantLine, gotLine = "<end of file>", "<end of file>"
+			if i < len(wantLines) {
+				wantLine = wantLines[i]
+			}
+			if i < len(gotLines) {
+				gotLine = gotLines[i]
+			}
+			return i + 1, wantLine, gotLine
+		}
+	}
+}
+
+// TestGolden formats every testdata/**/NAME.input.md and compares the result
+// with NAME.golden.md next to it, which must be formatted already. Run with
+// -update to write the golden files instead.
+func TestGolden(t *testing.T) {
+	var inputs []string
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".input.md") {
+			inputs = append(inputs, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(input), "testdata/"), ".input.md")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := formatForGolden(t, src)
+
+			golden := strings.TrimSuffix(input, ".input.md") + ".golden.md"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(want, got) {
+				line, wantLine, gotLine := firstDifference(want, got)
+				t.Errorf("%s differs from the formatted input at line %d:\nwant %q\ngot  %q", golden, line, wantLine, gotLine)
+			}
+			if again := formatForGolden(t, want); !bytes.Equal(want, again) {
+				line, wantLine, gotLine := firstDifference(want, again)
+				t.Errorf("formatting %s changes it at line %d:\nwant %q\ngot  %q", golden, line, wantLine, gotLine)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tuxikus/mdfmt/markdown"
+	commonmark "gitlab.com/golang-commonmark/markdown"
+)
+
+var update = flag.Bool("update", false, "rewrite the list of passing examples in testdata")
+
+// The examples of the CommonMark spec 0.28 (https://spec.commonmark.org,
+// CC-BY-SA 4.0) and the numbers of the examples mdfmt formats correctly.
+const (
+	specFile    = "testdata/spec-0.28.json"
+	passingFile = "testdata/passing.txt"
+)
+
+type specExample struct {
+	Example  int    `json:"example"`
+	Section  string `json:"section"`
+	Markdown string `json:"markdown"`
+}
+
+// renderer is a port of markdown-it, not the reference implementation, and
+// renders some examples differently from the spec. That does not matter here,
+// it only has to render an example the same before and after formatting.
+var renderer = commonmark.New(
+	commonmark.HTML(true),
+	commonmark.XHTMLOutput(true),
+	commonmark.Linkify(false),
+	commonmark.Typographer(false),
+	commonmark.LangPrefix("language-"),
+)
+
+// checkSpecExample returns why formatting the example fails, the formatted
+// document must render to the same HTML and stay the same when formatted
+// again.
+func checkSpecExample(ex specExample) error {
+	formatted, err := formatSpecExample(ex.Markdown)
+	if err != nil {
+		return err
+	}
+	if want, got := renderer.RenderToString([]byte(ex.Markdown)), renderer.RenderToString([]byte(formatted)); want != got {
+		return fmt.Errorf("rendering changed from\n%s\nto\n%s\nformatted as\n%s", want, got, formatted)
+	}
+
+	again, err := formatSpecExample(formatted)
+	if err != nil {
+		return err
+	}
+	if again != formatted {
+		return fmt.Errorf("formatting is not idempotent, first\n%s\nthen\n%s", formatted, again)
+	}
+
+	return nil
+}
+
+func formatSpecExample(src string) (string, error) {
+	doc, err := markdown.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := markdown.Format(&buf, doc, markdown.DefaultFormatOptions()); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func readPassingExamples(t *testing.T) map[int]bool {
+	data, err := os.ReadFile(passingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passing := map[int]bool{}
+	for _, field := range strings.Fields(string(data)) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			t.Fatalf("%s: %v", passingFile, err)
+		}
+		passing[n] = true
+	}
+
+	return passing
+}
+
+// TestCommonMarkSpec formats every example of the spec and fails if one of
+// the examples listed in passingFile no longer passes. Run with -v for the
+// pass rate per section and with -update to rewrite passingFile.
+func TestCommonMarkSpec(t *testing.T) {
+	data, err := os.ReadFile(specFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var examples []specExample
+	if err := json.Unmarshal(data, &examples); err != nil {
+		t.Fatal(err)
+	}
+	passing := readPassingExamples(t)
+
+	var sections []string
+	passed := map[string]int{}
+	total := map[string]int{}
+	var nowPassing, newlyPassing []int
+	for _, ex := range examples {
+		if total[ex.Section] == 0 {
+			sections = append(sections, ex.Section)
+		}
+		total[ex.Section]++
+
+		err := checkSpecExample(ex)
+		switch {
+		case err == nil:
+			passed[ex.Section]++
+			nowPassing = append(nowPassing, ex.Example)
+			if !passing[ex.Example] {
+				newlyPassing = append(newlyPassing, ex.Example)
+			}
+		case passing[ex.Example] && !*update:
+			t.Errorf("example %d (%s) no longer passes: %v\ninput\n%s", ex.Example, ex.Section, err, ex.Markdown)
+		}
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "%-40s %6s %6s %5s\n", "section", "passed", "total", "rate")
+	for _, section := range sections {
+		fmt.Fprintf(&report, "%-40s %6d %6d %4d%%\n", section, passed[section], total[section], 100*passed[section]/total[section])
+	}
+	fmt.Fprintf(&report, "%-40s %6d %6d %4d%%", "all", len(nowPassing), len(examples), 100*len(nowPassing)/len(examples))
+	t.Log("CommonMark spec examples\n" + report.String())
+
+	if *update {
+		var list strings.Builder
+		for _, n := range nowPassing {
+			fmt.Fprintln(&list, n)
+		}
+		if err := os.WriteFile(passingFile, []byte(list.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	} else if len(newlyPassing) > 0 {
+		t.Logf("examples %v pass now, run with -update to add them to %s", newlyPassing, passingFile)
+	}
+}
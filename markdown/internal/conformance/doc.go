@@ -0,0 +1,5 @@
+// Package conformance formats the examples of the CommonMark spec with the
+// markdown package and checks that their rendering does not change. It is a
+// module of its own, so that the HTML renderer it needs does not become a
+// dependency of mdfmt.
+package conformance
@@ -0,0 +1,18 @@
+module github.com/tuxikus/mdfmt/markdown/internal/conformance
+
+go 1.24.4
+
+require (
+	github.com/tuxikus/mdfmt v0.0.0
+	gitlab.com/golang-commonmark/markdown v0.0.0-20211110145824-bf3e522c626a
+)
+
+require (
+	gitlab.com/golang-commonmark/html v0.0.0-20191124015941-a22733972181 // indirect
+	gitlab.com/golang-commonmark/linkify v0.0.0-20191026162114-a0c2df6c8f82 // indirect
+	gitlab.com/golang-commonmark/mdurl v0.0.0-20191124015652-932350d1cb84 // indirect
+	gitlab.com/golang-commonmark/puny v0.0.0-20191124015043-9f83538fa04f // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
+
+replace github.com/tuxikus/mdfmt => ../../..
@@ -0,0 +1,334 @@
+package markdown
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSON form of the node tree written by EncodeJSON and read by DecodeJSON:
+//
+//	{
+//	  "type": "heading",
+//	  "span": {"start": {"line": 1, "column": 1, "offset": 0}, "end": {...}},
+//	  "level": 1,
+//	  "text": "Title"
+//	}
+//
+// Every node has a type and a span, followed by the attributes of its type
+// and, for nodes holding other nodes, its children:
+//
+//	document       children
+//	heading        level, text
+//	paragraph      text
+//	list           ordered, loose, marker or start and delimiter, children
+//	list_element   ordered, marker or number and delimiter, children
+//	table          alignments (none, left, center or right), children
+//	table_row      children
+//	table_element  text
+//	code_block     info, fence_char, fence_length, text
+//	blockquote     children
+//
+// Markers, delimiters and fence characters are strings of a single
+// character, alignments is null for tables without a delimiter row and the
+// delimiter row is the second row otherwise.
+
+var nodeTypeNames = [...]string{
+	NodeTypeDocument:     "document",
+	NodeTypeHeading:      "heading",
+	NodeTypeParagraph:    "paragraph",
+	NodeTypeList:         "list",
+	NodeTypeListElement:  "list_element",
+	NodeTypeTable:        "table",
+	NodeTypeTableRow:     "table_row",
+	NodeTypeTableElement: "table_element",
+	NodeTypeCodeBlock:    "code_block",
+	NodeTypeBlockquote:   "blockquote",
+}
+
+var alignmentNames = [...]string{
+	AlignNone:   "none",
+	AlignLeft:   "left",
+	AlignCenter: "center",
+	AlignRight:  "right",
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+// jsonNode is a node of any type, the attributes not used by the type are
+// nil and left out.
+type jsonNode struct {
+	Type        string       `json:"type"`
+	Span        jsonSpan     `json:"span"`
+	Level       *int         `json:"level,omitempty"`
+	Ordered     *bool        `json:"ordered,omitempty"`
+	Loose       *bool        `json:"loose,omitempty"`
+	Marker      *string      `json:"marker,omitempty"`
+	Start       *int         `json:"start,omitempty"`
+	Number      *int         `json:"number,omitempty"`
+	Delimiter   *string      `json:"delimiter,omitempty"`
+	Alignments  *[]string    `json:"alignments,omitempty"`
+	Info        *string      `json:"info,omitempty"`
+	FenceChar   *string      `json:"fence_char,omitempty"`
+	FenceLength *int         `json:"fence_length,omitempty"`
+	Text        *string      `json:"text,omitempty"`
+	Children    *[]*jsonNode `json:"children,omitempty"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// EncodeJSON writes the tree rooted at node to w as indented JSON.
+func EncodeJSON(w io.Writer, node Node) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(toJSON(node))
+}
+
+func toJSON(node Node) *jsonNode {
+	span := node.Span()
+	jn := &jsonNode{
+		Type: nodeTypeNames[node.Type()],
+		Span: jsonSpan{
+			Start: jsonPosition(span.Start),
+			End:   jsonPosition(span.End),
+		},
+	}
+
+	switch n := node.(type) {
+	case *Heading:
+		jn.Level = ptr(n.Level)
+		jn.Text = ptr(n.Text)
+	case *Paragraph:
+		jn.Text = ptr(n.Text)
+	case *List:
+		jn.Ordered = ptr(n.Ordered)
+		jn.Loose = ptr(n.Loose)
+		if n.Ordered {
+			jn.Start = ptr(n.Start)
+			jn.Delimiter = ptr(string(n.Delimiter))
+		} else {
+			jn.Marker = ptr(string(n.Marker))
+		}
+	case *ListElement:
+		jn.Ordered = ptr(n.Ordered)
+		if n.Ordered {
+			jn.Number = ptr(n.Number)
+			jn.Delimiter = ptr(string(n.Delimiter))
+		} else {
+			jn.Marker = ptr(string(n.Marker))
+		}
+	case *Table:
+		var alignments []string
+		for _, a := range n.Alignments {
+			alignments = append(alignments, alignmentNames[a])
+		}
+		jn.Alignments = &alignments
+	case *TableElement:
+		jn.Text = ptr(n.Text)
+	case *CodeBlock:
+		jn.Info = ptr(n.Info)
+		jn.FenceChar = ptr(string(n.FenceChar))
+		jn.FenceLength = ptr(n.FenceLength)
+		jn.Text = ptr(n.Text)
+	}
+
+	if hasChildren(node) {
+		children := make([]*jsonNode, 0, len(node.Children()))
+		for _, child := range node.Children() {
+			children = append(children, toJSON(child))
+		}
+		jn.Children = &children
+	}
+
+	return jn
+}
+
+// DecodeJSON reads a tree written by EncodeJSON from r, possibly modified,
+// and returns its root. Missing attributes are zero, except for the fences
+// of code blocks (```) and the delimiters of ordered lists (.), and missing
+// spans are zero as well.
+func DecodeJSON(r io.Reader) (Node, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var jn *jsonNode
+	if err := dec.Decode(&jn); err != nil {
+		return nil, err
+	}
+
+	return fromJSON(jn, "$")
+}
+
+// fromJSON converts jn to a node, path locates jn in errors.
+func fromJSON(jn *jsonNode, path string) (Node, error) {
+	if jn == nil {
+		return nil, fmt.Errorf("%s: node is null", path)
+	}
+
+	span := Span{Start: Position(jn.Span.Start), End: Position(jn.Span.End)}
+	var node Node
+	var err error
+	switch jn.Type {
+	case "document":
+		node = &Document{span: span}
+	case "heading":
+		level := deref(jn.Level)
+		if level < 1 {
+			return nil, fmt.Errorf("%s: heading level %d is less than 1", path, level)
+		}
+		node = &Heading{Level: level, Text: deref(jn.Text), span: span}
+	case "paragraph":
+		node = &Paragraph{Text: deref(jn.Text), span: span}
+	case "list":
+		list := &List{Ordered: deref(jn.Ordered), Loose: deref(jn.Loose), Start: deref(jn.Start), span: span}
+		list.Marker, list.Delimiter, err = decodeMarker(jn, path)
+		node = list
+	case "list_element":
+		elem := &ListElement{Ordered: deref(jn.Ordered), Number: deref(jn.Number), span: span}
+		elem.Marker, elem.Delimiter, err = decodeMarker(jn, path)
+		node = elem
+	case "table":
+		table := &Table{span: span}
+		if jn.Alignments != nil && *jn.Alignments != nil {
+			table.Alignments, err = decodeAlignments(*jn.Alignments, path)
+		}
+		node = table
+	case "table_row":
+		node = &TableRow{span: span}
+	case "table_element":
+		node = &TableElement{Text: deref(jn.Text), span: span}
+	case "code_block":
+		codeBlock := &CodeBlock{Info: deref(jn.Info), FenceChar: '`', FenceLength: 3, Text: deref(jn.Text), span: span}
+		if jn.FenceChar != nil {
+			if *jn.FenceChar != "`" && *jn.FenceChar != "~" {
+				return nil, fmt.Errorf("%s: invalid fence character %q", path, *jn.FenceChar)
+			}
+			codeBlock.FenceChar = (*jn.FenceChar)[0]
+		}
+		if jn.FenceLength != nil {
+			if *jn.FenceLength < 3 {
+				return nil, fmt.Errorf("%s: fence length %d is less than 3", path, *jn.FenceLength)
+			}
+			codeBlock.FenceLength = *jn.FenceLength
+		}
+		node = codeBlock
+	case "blockquote":
+		node = &Blockquote{span: span}
+	default:
+		return nil, fmt.Errorf("%s: unknown node type %q", path, jn.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if jn.Children == nil {
+		return node, nil
+	}
+	if !hasChildren(node) {
+		return nil, fmt.Errorf("%s: %s cannot have children", path, jn.Type)
+	}
+	var children []Node
+	for i, jc := range *jn.Children {
+		childPath := fmt.Sprintf("%s.children[%d]", path, i)
+		child, err := fromJSON(jc, childPath)
+		if err != nil {
+			return nil, err
+		}
+		if !fitsParent(node, child) {
+			return nil, fmt.Errorf("%s: %s cannot be a child of %s", childPath, jc.Type, jn.Type)
+		}
+		children = append(children, child)
+	}
+	setChildren(node, children)
+	if table, ok := node.(*Table); ok && table.Alignments != nil && len(table.rows) > 1 {
+		table.delimiterRow = 1
+	}
+
+	return node, nil
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+// decodeMarker returns the marker and delimiter of a list or list element.
+func decodeMarker(jn *jsonNode, path string) (marker, delimiter byte, err error) {
+	if deref(jn.Ordered) {
+		delimiter = '.'
+		if jn.Delimiter != nil {
+			if *jn.Delimiter != "." && *jn.Delimiter != ")" {
+				return 0, 0, fmt.Errorf("%s: invalid delimiter %q", path, *jn.Delimiter)
+			}
+			delimiter = (*jn.Delimiter)[0]
+		}
+		return 0, delimiter, nil
+	}
+
+	if jn.Marker != nil && *jn.Marker != "" {
+		if *jn.Marker != "-" && *jn.Marker != "*" && *jn.Marker != "+" {
+			return 0, 0, fmt.Errorf("%s: invalid marker %q", path, *jn.Marker)
+		}
+		marker = (*jn.Marker)[0]
+	}
+	return marker, 0, nil
+}
+
+func decodeAlignments(names []string, path string) ([]Alignment, error) {
+	alignments := make([]Alignment, 0, len(names))
+	for _, name := range names {
+		found := false
+		for a, alignmentName := range alignmentNames {
+			if name == alignmentName {
+				alignments = append(alignments, Alignment(a))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: invalid alignment %q", path, name)
+		}
+	}
+	return alignments, nil
+}
+
+// hasChildren reports whether node is of a type holding other nodes.
+func hasChildren(node Node) bool {
+	switch node.Type() {
+	case NodeTypeDocument, NodeTypeList, NodeTypeListElement, NodeTypeTable, NodeTypeTableRow, NodeTypeBlockquote:
+		return true
+	}
+	return false
+}
+
+// fitsParent reports whether child can be a child of parent.
+func fitsParent(parent, child Node) bool {
+	switch parent.Type() {
+	case NodeTypeList:
+		return child.Type() == NodeTypeListElement
+	case NodeTypeTable:
+		return child.Type() == NodeTypeTableRow
+	case NodeTypeTableRow:
+		return child.Type() == NodeTypeTableElement
+	}
+
+	switch child.Type() {
+	case NodeTypeHeading, NodeTypeParagraph, NodeTypeList, NodeTypeTable, NodeTypeCodeBlock, NodeTypeBlockquote:
+		return true
+	}
+	return false
+}
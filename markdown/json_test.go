@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := "# Title\n\n######### Deep\n\ntext\n\n- a\n\n  1) b\n  2) c\n\n| x | y |\n|:--|--:|\n| 1 | 2 |\n\n~~~~ go\ncode\n~~~~\n\n> quote\n"
+	doc := parse(input, DefaultFormatOptions())
+
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(doc, got) {
+		dumpForTest(t, doc, got)
+	}
+	if want := Fmt(doc, DefaultFormatOptions()); Fmt(got, DefaultFormatOptions()) != want {
+		printFmtForTest(t, want, Fmt(got, DefaultFormatOptions()), got)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	want := `{
+  "type": "document",
+  "span": {
+    "start": {
+      "line": 0,
+      "column": 0,
+      "offset": 0
+    },
+    "end": {
+      "line": 0,
+      "column": 0,
+      "offset": 0
+    }
+  },
+  "children": [
+    {
+      "type": "table",
+      "span": {
+        "start": {
+          "line": 0,
+          "column": 0,
+          "offset": 0
+        },
+        "end": {
+          "line": 0,
+          "column": 0,
+          "offset": 0
+        }
+      },
+      "alignments": null,
+      "children": []
+    }
+  ]
+}
+`
+
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, &Document{children: []Node{&Table{}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); want != got {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestDecodeJSONDefaults(t *testing.T) {
+	input := `{"type": "document", "children": [
+		{"type": "list", "ordered": true, "children": [
+			{"type": "list_element", "ordered": true, "number": 3, "children": [
+				{"type": "code_block", "text": "x\n"}
+			]}
+		]}
+	]}`
+	want := "3. ```\n   x\n   ```"
+
+	doc, err := DecodeJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Fmt(doc, DefaultFormatOptions()); want != got {
+		printFmtForTest(t, want, got, doc)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"type": "section"}`, `$: unknown node type "section"`},
+		{`{"type": "heading", "level": 0}`, "$: heading level 0 is less than 1"},
+		{`{"type": "paragraph", "children": []}`, "$: paragraph cannot have children"},
+		{`{"type": "document", "children": [null]}`, "$.children[0]: node is null"},
+		{`{"type": "list", "children": [{"type": "paragraph"}]}`, "$.children[0]: paragraph cannot be a child of list"},
+		{`{"type": "document", "children": [{"type": "table_row"}]}`, "$.children[0]: table_row cannot be a child of document"},
+		{`{"type": "list_element", "marker": "x"}`, `$: invalid marker "x"`},
+		{`{"type": "list", "ordered": true, "delimiter": ":"}`, `$: invalid delimiter ":"`},
+		{`{"type": "table", "alignments": ["middle"]}`, `$: invalid alignment "middle"`},
+		{`{"type": "code_block", "fence_char": "'"}`, `$: invalid fence character "'"`},
+		{`{"type": "code_block", "fence_length": 2}`, "$: fence length 2 is less than 3"},
+		{`{"type": "paragraph", "txt": "a"}`, `json: unknown field "txt"`},
+	}
+
+	for _, test := range tests {
+		_, err := DecodeJSON(strings.NewReader(test.input))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: want error %q, got %v", test.input, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package markdown parses Markdown documents into a tree of nodes and
+// formats them back into consistently styled Markdown.
+package markdown
+
+import "fmt"
+
+// Markdown elements important for formatting:
+// Document:
+//
+// # Heading(level: 1, text: Heading)
+// Paragraph(text)
+//
+// ## Heading(level, text)
+// Paragraph(text)
+//
+// ### Heading(level, text)
+// List
+// - ListElement(Paragraph)
+// - ListElement
+// - ListElement
+//
+// List
+// * ListElement
+// * ListElement
+// * ListElement
+//
+// List
+// - ListElement(Paragraph, List)
+//   - ListElement(Paragraph, List)
+//     - ListElement(Paragraph)
+//     - ListElement
+//
+//
+// #### Heading
+// | Table | Table |
+// | ----- | ----- |
+// | Table | Table |
+//
+//
+
+type NodeType int
+
+const (
+	NodeTypeDocument = iota
+	NodeTypeHeading
+	NodeTypeParagraph
+	NodeTypeList
+	NodeTypeListElement
+	NodeTypeTable
+	NodeTypeTableRow
+	NodeTypeTableElement
+	NodeTypeCodeBlock
+	NodeTypeBlockquote
+)
+
+type Node interface {
+	Type() NodeType
+	Children() []Node
+	// Span returns the source range the node was parsed from, it is zero
+	// for nodes not created by Parse.
+	Span() Span
+}
+
+// Position is a location in the source of a document. Line and Column start
+// at 1, Offset starts at 0, Column and Offset count bytes.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// advance returns the position n bytes further on the same line.
+func (p Position) advance(n int) Position {
+	p.Column += n
+	p.Offset += n
+	return p
+}
+
+// Span is the source range of a node, from the first byte at Start up to
+// End, which is the position just after the last byte.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+var _ Node = (*Document)(nil)
+
+type Document struct {
+	children []Node
+	span     Span
+}
+
+func (d *Document) Type() NodeType   { return NodeTypeDocument }
+func (d *Document) Children() []Node { return d.children }
+func (d *Document) Span() Span       { return d.span }
+
+var _ Node = (*Heading)(nil)
+
+type Heading struct {
+	Level int
+	Text  string
+	span  Span
+}
+
+func (h *Heading) Type() NodeType   { return NodeTypeHeading }
+func (h *Heading) Children() []Node { return nil }
+func (h *Heading) Span() Span       { return h.span }
+
+var _ Node = (*List)(nil)
+
+// List is a bullet or ordered list. Marker, Ordered, Start and Delimiter
+// describe its elements, nested lists are children of the elements. Loose
+// lists separate their elements and the blocks in them by blank lines.
+type List struct {
+	elements  []Node
+	Marker    byte
+	Ordered   bool
+	Start     int
+	Delimiter byte
+	Loose     bool
+	span      Span
+}
+
+func (l *List) Type() NodeType   { return NodeTypeList }
+func (l *List) Children() []Node { return l.elements }
+func (l *List) Span() Span       { return l.span }
+
+var _ Node = (*ListElement)(nil)
+
+// ListElement is a single list item, its children are the blocks of the item
+// starting with the text after the marker. Marker (-, * or +) is only set for
+// bullet elements, Number and Delimiter (. or )) only for ordered elements.
+type ListElement struct {
+	children  []Node
+	Marker    byte
+	Ordered   bool
+	Number    int
+	Delimiter byte
+	span      Span
+}
+
+func (le *ListElement) Type() NodeType   { return NodeTypeListElement }
+func (le *ListElement) Children() []Node { return le.children }
+func (le *ListElement) Span() Span       { return le.span }
+
+var _ Node = (*Paragraph)(nil)
+
+type Paragraph struct {
+	Text string
+	span Span
+}
+
+func (p *Paragraph) Type() NodeType   { return NodeTypeParagraph }
+func (p *Paragraph) Children() []Node { return nil }
+func (p *Paragraph) Span() Span       { return p.span }
+
+var _ Node = (*Table)(nil)
+
+// Alignment is the alignment of a table column set by the colons in the
+// delimiter row.
+type Alignment int
+
+const (
+	AlignNone   Alignment = iota // ---
+	AlignLeft                    // :---
+	AlignCenter                  // :---:
+	AlignRight                   // ---:
+)
+
+// Table holds all rows, including the delimiter row. Alignments is nil if
+// the table has no delimiter row.
+type Table struct {
+	rows       []Node
+	Alignments []Alignment
+	span       Span
+
+	// delimiterRow is the index of the delimiter row in rows, or 0 if there
+	// is none, as it follows the header row
+	delimiterRow int
+}
+
+func (t *Table) Type() NodeType   { return NodeTypeTable }
+func (t *Table) Children() []Node { return t.rows }
+func (t *Table) Span() Span       { return t.span }
+
+var _ Node = (*TableRow)(nil)
+
+type TableRow struct {
+	elements []Node
+	span     Span
+}
+
+func (tr *TableRow) Type() NodeType   { return NodeTypeTableRow }
+func (tr *TableRow) Children() []Node { return tr.elements }
+func (tr *TableRow) Span() Span       { return tr.span }
+
+var _ Node = (*TableElement)(nil)
+
+type TableElement struct {
+	Text string
+	span Span
+}
+
+func (te *TableElement) Type() NodeType   { return NodeTypeTableElement }
+func (te *TableElement) Children() []Node { return nil }
+func (te *TableElement) Span() Span       { return te.span }
+
+var _ Node = (*CodeBlock)(nil)
+
+// CodeBlock is a fenced code block. Text holds the content lines exactly as
+// they appeared between the fences, each terminated by a newline.
+type CodeBlock struct {
+	Info        string
+	FenceChar   byte
+	FenceLength int
+	Text        string
+	span        Span
+}
+
+func (cb *CodeBlock) Type() NodeType   { return NodeTypeCodeBlock }
+func (cb *CodeBlock) Children() []Node { return nil }
+func (cb *CodeBlock) Span() Span       { return cb.span }
+
+var _ Node = (*Blockquote)(nil)
+
+// Blockquote holds the blocks parsed from its lines with the > removed.
+type Blockquote struct {
+	children []Node
+	span     Span
+}
+
+func (bq *Blockquote) Type() NodeType   { return NodeTypeBlockquote }
+func (bq *Blockquote) Children() []Node { return bq.children }
+func (bq *Blockquote) Span() Span       { return bq.span }
+
+func dump(nodes []Node) {
+	for i := range nodes {
+		switch nodes[i].Type() {
+		case NodeTypeDocument:
+			fmt.Println("Document:")
+		case NodeTypeHeading:
+			fmt.Printf("Heading(lvl: %d, text: %s)\n", nodes[i].(*Heading).Level, nodes[i].(*Heading).Text)
+		case NodeTypeParagraph:
+			fmt.Printf("Paragraph(text: %s)\n", nodes[i].(*Paragraph).Text)
+		case NodeTypeList:
+			if nodes[i].(*List).Ordered {
+				fmt.Printf("List(ordered, start: %d)\n", nodes[i].(*List).Start)
+			} else {
+				fmt.Println("List")
+			}
+		case NodeTypeListElement:
+			if nodes[i].(*ListElement).Ordered {
+				fmt.Printf("ListElement(number: %d)\n", nodes[i].(*ListElement).Number)
+			} else {
+				fmt.Println("ListElement")
+			}
+		case NodeTypeTable:
+			fmt.Println("Table")
+		case NodeTypeTableRow:
+			fmt.Println("TableRow")
+		case NodeTypeTableElement:
+			fmt.Printf("TableElement(text: %s)\n", nodes[i].(*TableElement).Text)
+		case NodeTypeCodeBlock:
+			fmt.Printf("CodeBlock(info: %s, text: %q)\n", nodes[i].(*CodeBlock).Info, nodes[i].(*CodeBlock).Text)
+		case NodeTypeBlockquote:
+			fmt.Println("Blockquote")
+		}
+
+		dump(nodes[i].Children())
+	}
+
+}
@@ -0,0 +1,802 @@
+package markdown
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// openingFence reports whether line opens a fenced code block and returns the
+// fence character, its length, the info string and the indentation of the
+// fence.
+func openingFence(line string) (char byte, length int, info string, indent int, ok bool) {
+	for indent < len(line) && indent < 4 && line[indent] == ' ' {
+		indent++
+	}
+	if indent > 3 || indent == len(line) {
+		return 0, 0, "", 0, false
+	}
+
+	char = line[indent]
+	if char != '`' && char != '~' {
+		return 0, 0, "", 0, false
+	}
+
+	for indent+length < len(line) && line[indent+length] == char {
+		length++
+	}
+	if length < 3 {
+		return 0, 0, "", 0, false
+	}
+
+	info = strings.TrimSpace(line[indent+length:])
+	// backtick fences must not contain backticks in the info string
+	if char == '`' && strings.Contains(info, "`") {
+		return 0, 0, "", 0, false
+	}
+
+	return char, length, info, indent, true
+}
+
+// isClosingFence reports whether line closes a fence opened with char
+// repeated length times.
+func isClosingFence(line string, char byte, length int) bool {
+	indent := 0
+	for indent < len(line) && indent < 4 && line[indent] == ' ' {
+		indent++
+	}
+	if indent > 3 {
+		return false
+	}
+
+	n := 0
+	for indent+n < len(line) && line[indent+n] == char {
+		n++
+	}
+
+	return n >= length && strings.TrimSpace(line[indent+n:]) == ""
+}
+
+// expandTabs replaces every tab in line with tabWidth spaces.
+func expandTabs(line string, tabWidth int) string {
+	return strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))
+}
+
+// cutColumns removes the first n columns of line, counting a tab as tabWidth
+// columns like expandTabs, and returns the rest and the offset in line the
+// rest starts at. A tab reaching past column n is replaced by the remaining
+// spaces, the offset is then moved back by their number so that it still
+// locates the text after the tab. Only the spaces themselves, which no span
+// starts or ends in, get positions before the tab.
+func cutColumns(line string, n, tabWidth int) (string, int) {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		if col >= n {
+			return line[i:], i
+		}
+
+		if line[i] != '\t' {
+			col++
+			continue
+		}
+		col += tabWidth
+		if col > n {
+			return strings.Repeat(" ", col-n) + line[i+1:], i + 1 - (col - n)
+		}
+	}
+
+	return "", len(line)
+}
+
+// listItem is a single list line split into its marker and text.
+type listItem struct {
+	indent    int // spaces before the marker
+	markerLen int
+	width     int // marker width including the spaces up to the text
+	marker    byte
+	ordered   bool
+	number    int
+	delimiter byte
+}
+
+// parseListItem reports whether line starts with a bullet (-, * or +) or an
+// ordered list marker (1. or 1)) and splits it into a listItem.
+func parseListItem(line string) (listItem, bool) {
+	item := listItem{}
+	for item.indent < len(line) && line[item.indent] == ' ' {
+		item.indent++
+	}
+	rest := line[item.indent:]
+
+	markerLen := 0
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		// trim all hyphens, "-foo" and "--" are list elements as well
+		item.marker = '-'
+		markerLen = len(rest) - len(strings.TrimLeft(rest, "-"))
+	case strings.HasPrefix(rest, "*") || strings.HasPrefix(rest, "+"):
+		// unlike hyphens these need a space, "*emphasis*" is no list
+		if len(rest) > 1 && rest[1] != ' ' && rest[1] != '\t' {
+			return listItem{}, false
+		}
+
+		item.marker = rest[0]
+		markerLen = 1
+	case len(rest) > 0 && rest[0] >= '0' && rest[0] <= '9':
+		for markerLen < len(rest) && markerLen < 10 && rest[markerLen] >= '0' && rest[markerLen] <= '9' {
+			markerLen++
+		}
+		// at most 9 digits followed by . or ) and a space
+		if markerLen > 9 || markerLen == len(rest) || (rest[markerLen] != '.' && rest[markerLen] != ')') {
+			return listItem{}, false
+		}
+		if markerLen+1 < len(rest) && rest[markerLen+1] != ' ' && rest[markerLen+1] != '\t' {
+			return listItem{}, false
+		}
+
+		item.ordered = true
+		item.number, _ = strconv.Atoi(rest[:markerLen])
+		item.delimiter = rest[markerLen]
+		markerLen++
+	default:
+		return listItem{}, false
+	}
+
+	spaces := len(rest[markerLen:]) - len(strings.TrimLeft(rest[markerLen:], " "))
+	if spaces == 0 || spaces > 4 || markerLen+spaces == len(rest) {
+		spaces = 1
+	}
+
+	item.markerLen = markerLen
+	item.width = markerLen + spaces
+
+	return item, true
+}
+
+// isThematicBreak reports whether line is a thematic break, three or more
+// -, * or _ with nothing but spaces in between.
+func isThematicBreak(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || trimmed == "" {
+		return false
+	}
+
+	char := trimmed[0]
+	if char != '-' && char != '*' && char != '_' {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(trimmed); i++ {
+		switch trimmed[i] {
+		case char:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+
+	return n >= 3
+}
+
+// isSetextUnderline reports whether line is a thematic break of hyphens only,
+// which underlines the paragraph before it as a setext heading.
+func isSetextUnderline(line string) bool {
+	return isThematicBreak(line) && strings.Trim(strings.TrimSpace(line), "-") == ""
+}
+
+// atxHeading reports whether line is a heading, a run of # followed by a
+// space, a tab or the end of the line, and returns its level and the index
+// of its text.
+func atxHeading(line string) (level, textStart int, ok bool) {
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 {
+		return 0, 0, false
+	}
+	if level == len(line) {
+		return level, level, true
+	}
+	if line[level] != ' ' && line[level] != '\t' {
+		return 0, 0, false
+	}
+
+	return level, level + 1, true
+}
+
+// quoteMarker returns the length of the blockquote marker at the start of
+// line, the > with up to three spaces before and one space after it, or 0 if
+// line is not quoted.
+func quoteMarker(line string) int {
+	indent := 0
+	for indent < len(line) && indent < 4 && line[indent] == ' ' {
+		indent++
+	}
+	if indent > 3 || indent == len(line) || line[indent] != '>' {
+		return 0
+	}
+
+	if indent+1 < len(line) && line[indent+1] == ' ' {
+		return indent + 2
+	}
+
+	return indent + 1
+}
+
+// tableCell is a cell of a table line, start and end are the byte offsets
+// of its trimmed text in the line.
+type tableCell struct {
+	text       string
+	start, end int
+}
+
+// splitTableRow splits a table line into its trimmed cells. Escaped pipes
+// (\|) and pipes inside code spans do not separate cells and are kept as
+// written.
+func splitTableRow(line string) []tableCell {
+	trimmed := strings.TrimSpace(line)
+	i := strings.Index(line, trimmed)
+	end := i + len(trimmed)
+	if strings.HasPrefix(trimmed, "|") {
+		i++
+	}
+
+	cells := make([]tableCell, 0)
+	addCell := func(from, to int) {
+		text := strings.TrimSpace(line[from:to])
+		from += strings.Index(line[from:to], text)
+		cells = append(cells, tableCell{text: text, start: from, end: from + len(text)})
+	}
+
+	cellStart := i
+	code := 0 // length of the backtick run opening the current code span
+	for ; i < end; i++ {
+		switch c := line[i]; {
+		// backslash escapes do not work in code spans, except for pipes
+		case c == '\\' && i+1 < end && (code == 0 || line[i+1] == '|'):
+			i++
+		case c == '`':
+			n := 1
+			for i+n < end && line[i+n] == '`' {
+				n++
+			}
+			if code == 0 && closingBackticks(line[i+n:end], n) {
+				code = n
+			} else if code == n {
+				code = 0
+			}
+			i += n - 1
+		case c == '|' && code == 0:
+			addCell(cellStart, i)
+			cellStart = i + 1
+		}
+	}
+
+	// the text after the last pipe is a cell, unless the row ends with a pipe
+	if strings.TrimSpace(line[cellStart:end]) != "" || len(cells) == 0 {
+		addCell(cellStart, end)
+	}
+
+	return cells
+}
+
+// Parse reads a Markdown document from r and returns its tree.
+func Parse(r io.Reader) (*Document, error) {
+	return ParseWithOptions(r, DefaultFormatOptions())
+}
+
+// ParseWithOptions is like Parse, but uses the TabWidth of opts to measure
+// the indentation of list elements. Bytes that are not valid UTF-8 are kept
+// as they are. A failure of the parser itself is returned as an *Error
+// wrapping ErrInternal.
+func ParseWithOptions(r io.Reader, opts FormatOptions) (doc *Document, err error) {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			doc, err = nil, internalError(v, 0)
+		}
+	}()
+
+	return parse(string(in), opts), nil
+}
+
+func parse(in string, opts FormatOptions) *Document {
+	lines := strings.Split(in, "\n")
+	starts := make([]Position, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = Position{Line: i + 1, Column: 1, Offset: offset}
+		offset += len(line) + 1
+	}
+	end := starts[len(lines)-1].advance(len(lines[len(lines)-1]))
+
+	// line endings are normalized, the formatter writes opts.LineEnding
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	return &Document{
+		children: parseBlocks(lines, starts, opts),
+		span:     Span{Start: starts[0], End: end},
+	}
+}
+
+// textStart returns the position of the first non-blank byte of lines[i],
+// starts[i] is the position of its first byte.
+func textStart(lines []string, starts []Position, i int) Position {
+	return starts[i].advance(len(lines[i]) - len(strings.TrimLeft(lines[i], " \t")))
+}
+
+// textEnd returns the position just after the last non-blank byte of
+// lines[i].
+func textEnd(lines []string, starts []Position, i int) Position {
+	return starts[i].advance(len(strings.TrimRight(lines[i], " \t")))
+}
+
+// parseBlocks parses lines into block nodes, starts holds the source
+// position of the first byte of every line.
+func parseBlocks(lines []string, starts []Position, opts FormatOptions) []Node {
+	var nodes []Node
+	line := 0
+	defer panicAt(&line)
+
+	for i := 0; i < len(lines); i++ {
+		// skip empty lines
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		line = starts[i].Line
+
+		// fenced code block
+		if char, length, info, indent, ok := openingFence(lines[i]); ok {
+			span := Span{Start: starts[i].advance(indent), End: textEnd(lines, starts, i)}
+			i++
+			codeStart := i
+			for i < len(lines) && !isClosingFence(lines[i], char, length) {
+				i++
+			}
+
+			codeLines := lines[codeStart:i]
+			// an unclosed fence runs to the end of the document
+			if i == len(lines) {
+				for len(codeLines) > 0 && strings.TrimSpace(codeLines[len(codeLines)-1]) == "" {
+					codeLines = codeLines[:len(codeLines)-1]
+				}
+				if len(codeLines) > 0 {
+					span.End = starts[codeStart+len(codeLines)-1].advance(len(codeLines[len(codeLines)-1]))
+				}
+			} else {
+				span.End = textEnd(lines, starts, i)
+			}
+
+			text := strings.Builder{}
+			for _, codeLine := range codeLines {
+				// remove up to indent spaces, like the opening fence
+				j := 0
+				for j < indent && j < len(codeLine) && codeLine[j] == ' ' {
+					j++
+				}
+				text.WriteString(codeLine[j:])
+				text.WriteString("\n")
+			}
+
+			nodes = append(nodes, &CodeBlock{
+				Info:        info,
+				FenceChar:   char,
+				FenceLength: length,
+				Text:        text.String(),
+				span:        span,
+			})
+
+			continue
+		}
+
+		// blockquote
+		// > quoted text
+		// > > nested quote
+		// lazy continuation of the quoted text
+		if quoteMarker(lines[i]) > 0 {
+			span := Span{Start: textStart(lines, starts, i)}
+			content := containerLines{}
+			for i < len(lines) {
+				if n := quoteMarker(lines[i]); n > 0 {
+					content.add(lines[i][n:], starts[i].advance(n))
+				} else if isLazyLine(strings.TrimLeft(lines[i], " \t"), nextLine(lines, i), opts.TabWidth) && content.endsInParagraph(opts) {
+					content.add(strings.TrimLeft(lines[i], " \t"), textStart(lines, starts, i))
+				} else {
+					break
+				}
+				span.End = textEnd(lines, starts, i)
+				i++
+			}
+
+			nodes = append(nodes, &Blockquote{
+				children: parseBlocks(content.lines, content.starts, opts),
+				span:     span,
+			})
+
+			i--
+			continue
+		}
+
+		// heading
+		if lvl, textStart, ok := atxHeading(lines[i]); ok {
+			text := lines[i][textStart:]
+
+			nodes = append(nodes, &Heading{
+				Level: lvl,
+				Text:  text,
+				span:  Span{Start: starts[i], End: textEnd(lines, starts, i)},
+			})
+
+			continue
+		}
+
+		// list
+		// - list element
+		//   - nested list element
+		// 1. ordered list element
+		// but not a thematic break like - - -
+		if _, ok := parseListItem(expandTabs(lines[i], opts.TabWidth)); ok && !isThematicBreak(lines[i]) {
+			var list *List
+			list, i = parseList(lines, starts, i, opts)
+			nodes = append(nodes, list)
+
+			// continue but dont increment
+			i--
+			continue
+		}
+
+		// table
+		tableStart := i
+		if strings.HasPrefix(lines[i], "|") {
+			for i < len(lines) && strings.HasPrefix(lines[i], "|") {
+				i++
+			}
+
+			tableRows := make([]Node, 0)
+			for j := tableStart; j < i; j++ {
+				tableElements := make([]Node, 0)
+
+				for _, cell := range splitTableRow(lines[j]) {
+					tableElements = append(tableElements, &TableElement{
+						Text: cell.text,
+						span: Span{Start: starts[j].advance(cell.start), End: starts[j].advance(cell.end)},
+					})
+				}
+
+				tableRows = append(tableRows, &TableRow{
+					elements: tableElements,
+					span:     Span{Start: starts[j], End: textEnd(lines, starts, j)},
+				})
+			}
+
+			table := &Table{
+				rows: tableRows,
+				span: Span{Start: starts[tableStart], End: textEnd(lines, starts, i-1)},
+			}
+
+			// alignments from the delimiter row, which can only be the
+			// second one
+			if len(tableRows) > 1 {
+				cells := make([]string, 0)
+				for _, elemNode := range tableRows[1].(*TableRow).elements {
+					cells = append(cells, elemNode.(*TableElement).Text)
+				}
+				if isDelimiterRow(cells) {
+					table.delimiterRow = 1
+					table.Alignments = make([]Alignment, 0, len(cells))
+					for _, cell := range cells {
+						table.Alignments = append(table.Alignments, cellAlignment(cell))
+					}
+				}
+			}
+
+			nodes = append(nodes, table)
+
+			i--
+			continue
+		}
+
+		// paragraph
+		paragraphStart := i
+		for i < len(lines) &&
+			!(strings.TrimSpace(lines[i]) == "" ||
+				strings.HasPrefix(lines[i], "-") && !isThematicBreak(lines[i])) {
+			if i > paragraphStart && interruptsParagraph(lines[i], nextLine(lines, i), opts.TabWidth) {
+				break
+			}
+			i++
+			// so is a thematic break, it is kept on a line of its own,
+			// which makes a setext underline the last line of the
+			// paragraph it underlines
+			if isThematicBreak(lines[i-1]) {
+				break
+			}
+		}
+
+		// the indentation of the first line carries no meaning, unless it
+		// keeps the line from starting another block
+		text := strings.Join(lines[paragraphStart:i], "\n")
+		trimmed := strings.TrimLeft(text, " \t")
+		if firstLine, _, _ := strings.Cut(trimmed, "\n"); !startsBlock(firstLine) {
+			text = trimmed
+		}
+
+		nodes = append(nodes, &Paragraph{
+			Text: text,
+			span: Span{Start: textStart(lines, starts, paragraphStart), End: textEnd(lines, starts, i-1)},
+		})
+
+		i--
+	}
+
+	return nodes
+}
+
+// parseList parses the list starting at lines[i] and returns it with the
+// index of the line after it. The lines indented up to the text of an element
+// and lazy continuation lines of its last paragraph are parsed as its
+// children, which makes nested lists children of their parent element.
+func parseList(lines []string, starts []Position, i int, opts FormatOptions) (*List, int) {
+	first, _ := parseListItem(expandTabs(lines[i], opts.TabWidth))
+	list := &List{
+		Marker:    first.marker,
+		Ordered:   first.ordered,
+		Start:     first.number,
+		Delimiter: first.delimiter,
+		span:      Span{Start: textStart(lines, starts, i)},
+	}
+
+	for i < len(lines) {
+		// blank lines between elements make the list loose
+		next := i
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next == len(lines) {
+			break
+		}
+
+		item, ok := parseListItem(expandTabs(lines[next], opts.TabWidth))
+		// an element of another kind starts a new list, a thematic break
+		// ends it
+		if !ok || isThematicBreak(lines[next]) || item.marker != list.Marker || item.ordered != list.Ordered || item.delimiter != list.Delimiter {
+			break
+		}
+		if next > i {
+			list.Loose = true
+		}
+		i = next
+
+		// the text after the marker is the first line of the element
+		markerStart := len(lines[i]) - len(strings.TrimLeft(lines[i], " \t"))
+		afterMarker := lines[i][markerStart+item.markerLen:]
+		textIdx := markerStart + item.markerLen + len(afterMarker) - len(strings.TrimLeft(afterMarker, " \t"))
+
+		elem := &ListElement{
+			Marker:    item.marker,
+			Ordered:   item.ordered,
+			Number:    item.number,
+			Delimiter: item.delimiter,
+			span:      Span{Start: starts[i].advance(markerStart), End: textEnd(lines, starts, i)},
+		}
+		content := containerLines{}
+		content.add(lines[i][textIdx:], starts[i].advance(textIdx))
+		i++
+
+		contentIndent := item.indent + item.width
+	content:
+		for i < len(lines) {
+			line := expandTabs(lines[i], opts.TabWidth)
+
+			// blank lines belong to the element if its content goes on
+			// after them
+			if strings.TrimSpace(line) == "" {
+				next := i
+				for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+					next++
+				}
+				if next == len(lines) || indentation(expandTabs(lines[next], opts.TabWidth)) < contentIndent {
+					break
+				}
+
+				for ; i < next; i++ {
+					content.add("", starts[i])
+				}
+				continue
+			}
+
+			// the following lines indented up to the text belong to the
+			// element, less indented ones only if they continue its last
+			// paragraph
+			var text string
+			var start Position
+			switch {
+			case indentation(line) >= contentIndent:
+				var n int
+				text, n = cutColumns(lines[i], contentIndent, opts.TabWidth)
+				start = starts[i].advance(n)
+			case isLazyLine(strings.TrimLeft(lines[i], " \t"), nextLine(lines, i), opts.TabWidth) && content.endsInParagraph(opts):
+				text = strings.TrimLeft(lines[i], " \t")
+				start = textStart(lines, starts, i)
+			default:
+				break content
+			}
+
+			content.add(text, start)
+			elem.span.End = textEnd(lines, starts, i)
+			i++
+		}
+
+		elem.children = parseBlocks(content.lines, content.starts, opts)
+		// so do blank lines between the blocks of an element
+		for j := 1; j < len(elem.children); j++ {
+			if elem.children[j].Span().Start.Line > elem.children[j-1].Span().End.Line+1 {
+				list.Loose = true
+			}
+		}
+
+		list.elements = append(list.elements, elem)
+		list.span.End = elem.span.End
+	}
+
+	return list, i
+}
+
+// interruptsParagraph reports whether line, followed by next, starts a block
+// that ends the paragraph before it: a thematic break other than a setext
+// underline, a heading of level 1 to 6, a fence, a quote, the header row of
+// a table or a list as described by interruptsList.
+func interruptsParagraph(line, next string, tabWidth int) bool {
+	if isThematicBreak(line) {
+		return !isSetextUnderline(line)
+	}
+	if _, ok := parseListItem(expandTabs(line, tabWidth)); ok {
+		return interruptsList(line, tabWidth)
+	}
+	if _, _, _, _, ok := openingFence(line); ok {
+		return true
+	}
+	if level, _, ok := atxHeading(line); ok && level <= 6 {
+		return true
+	}
+
+	return quoteMarker(line) > 0 || startsTable(line, next)
+}
+
+// isLazyLine reports whether text, a line without its indentation followed by
+// next, is a lazy continuation of a paragraph in a list element or quote it is
+// not indented or marked for. List elements never are, they belong to the
+// list or end it.
+func isLazyLine(text, next string, tabWidth int) bool {
+	if _, ok := parseListItem(expandTabs(text, tabWidth)); ok {
+		return false
+	}
+
+	return strings.TrimSpace(text) != "" && !interruptsParagraph(text, next, tabWidth)
+}
+
+// startsTable reports whether line is the header row of a table, a row
+// followed by a delimiter row.
+func startsTable(line, next string) bool {
+	if !strings.HasPrefix(line, "|") || !strings.HasPrefix(next, "|") {
+		return false
+	}
+
+	cells := make([]string, 0)
+	for _, cell := range splitTableRow(next) {
+		cells = append(cells, cell.text)
+	}
+	return isDelimiterRow(cells)
+}
+
+// nextLine returns the line after lines[i], or "" if it is the last one.
+func nextLine(lines []string, i int) string {
+	if i+1 < len(lines) {
+		return lines[i+1]
+	}
+	return ""
+}
+
+// interruptsList reports whether line starts a list that ends the paragraph
+// before it. Like in CommonMark these are bullet lists and ordered lists
+// starting at 1, indented by at most three spaces and not empty.
+func interruptsList(line string, tabWidth int) bool {
+	line = expandTabs(line, tabWidth)
+	item, ok := parseListItem(line)
+	return ok && item.indent <= 3 && (!item.ordered || item.number == 1) &&
+		strings.TrimSpace(line[item.indent+item.markerLen:]) != ""
+}
+
+// indentation returns the number of spaces line starts with.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// containerLines collects the lines of a list element or blockquote and
+// tracks whether the blocks parsed from them end in a paragraph, which lazy
+// continuation lines are added to.
+type containerLines struct {
+	lines  []string
+	starts []Position
+
+	// last is the index of the first line of the last block, para reports
+	// whether the blocks end in a paragraph if known is set
+	last  int
+	known bool
+	para  bool
+}
+
+// add appends line, which starts at start.
+func (c *containerLines) add(line string, start Position) {
+	c.lines = append(c.lines, line)
+	c.starts = append(c.starts, start)
+
+	if c.known && c.para && !continuesParagraph(strings.TrimLeft(line, " \t")) {
+		c.known = false
+	}
+}
+
+// continuesParagraph reports whether the blocks still end in a paragraph
+// after adding a line with text to blocks ending in one. This is the case
+// for paragraph text and list elements starting with it.
+func continuesParagraph(text string) bool {
+	if item, ok := parseListItem(text); ok {
+		text = strings.TrimLeft(text[item.markerLen:], " \t")
+		return text != "" && !startsBlock(text)
+	}
+
+	return text != "" && !startsBlock(text)
+}
+
+// endsInParagraph reports whether the blocks parsed from the lines end in a
+// paragraph. Blocks are parsed independently of the ones before them, so only
+// the last block is parsed again, and only if a line was added that may have
+// changed it.
+func (c *containerLines) endsInParagraph(opts FormatOptions) bool {
+	if len(c.lines) == 0 || strings.TrimSpace(c.lines[len(c.lines)-1]) == "" {
+		return false
+	}
+
+	if !c.known {
+		nodes := parseBlocks(c.lines[c.last:], c.starts[c.last:], opts)
+		c.para = endsInParagraph(nodes)
+		c.known = true
+
+		if len(nodes) > 0 {
+			line := nodes[len(nodes)-1].Span().Start.Line
+			for c.last < len(c.lines)-1 && c.starts[c.last].Line != line {
+				c.last++
+			}
+		}
+	}
+
+	return c.para
+}
+
+// endsInParagraph reports whether the last of nodes is a paragraph, or a
+// list whose last element or a blockquote that ends with one.
+func endsInParagraph(nodes []Node) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+
+	switch last := nodes[len(nodes)-1].(type) {
+	case *Paragraph:
+		return true
+	case *List:
+		if len(last.elements) > 0 {
+			return endsInParagraph(last.elements[len(last.elements)-1].Children())
+		}
+	case *Blockquote:
+		return endsInParagraph(last.children)
+	}
+
+	return false
+}
@@ -0,0 +1,2072 @@
+package markdown
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dumpForTest(t *testing.T, want, got Node) {
+	t.Error("Parse result does not match expected output")
+	fmt.Println("=== want ===")
+	dump(want.Children())
+	fmt.Println("=== got ===")
+	dump(got.Children())
+}
+
+// equalIgnoringSpans reports whether the trees want and got are equal apart
+// from their spans, which are cleared in got.
+func equalIgnoringSpans(want, got Node) bool {
+	clearSpans(got)
+	return reflect.DeepEqual(want, got)
+}
+
+func clearSpans(node Node) {
+	switch n := node.(type) {
+	case *Document:
+		n.span = Span{}
+	case *Heading:
+		n.span = Span{}
+	case *Paragraph:
+		n.span = Span{}
+	case *List:
+		n.span = Span{}
+	case *ListElement:
+		n.span = Span{}
+	case *Table:
+		n.span = Span{}
+	case *TableRow:
+		n.span = Span{}
+	case *TableElement:
+		n.span = Span{}
+	case *CodeBlock:
+		n.span = Span{}
+	case *Blockquote:
+		n.span = Span{}
+	}
+
+	for _, child := range node.Children() {
+		clearSpans(child)
+	}
+}
+
+func TestParseEmptyDocument(t *testing.T) {
+	input := ""
+	want := &Document{}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseLevelOneHeading(t *testing.T) {
+	input := "# Foo"
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Foo",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseLevelTwoHeading(t *testing.T) {
+	input := "## Foo Bar"
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 2,
+				Text:  "Foo Bar",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseLevel9Heading(t *testing.T) {
+	input := "######### Foo Bar Baz"
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 9,
+				Text:  "Foo Bar Baz",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseSingleLineParagraph(t *testing.T) {
+	input := "Foo"
+	want := &Document{
+		children: []Node{
+			&Paragraph{
+				Text: "Foo",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseMultiLineParagraph(t *testing.T) {
+	input := `Foo Faz
+Bar Baz`
+	want := &Document{
+		children: []Node{
+			&Paragraph{
+				Text: "Foo Faz\nBar Baz",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingParagraph(t *testing.T) {
+	input := `# Heading
+Foo Faz
+Bar Baz`
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&Paragraph{
+				Text: "Foo Faz\nBar Baz",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingParagraphTailingNewLines(t *testing.T) {
+	input := `# Heading
+Foo Faz
+Bar Baz
+
+
+`
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&Paragraph{
+				Text: "Foo Faz\nBar Baz",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingTwoParagraphs(t *testing.T) {
+	input := `# Heading
+Foo Faz
+Bar Baz
+
+Second paragraph`
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&Paragraph{
+				Text: "Foo Faz\nBar Baz",
+			},
+			&Paragraph{
+				Text: "Second paragraph",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	input := "- Foo"
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseListMoreHyphens(t *testing.T) {
+	input := "- Foo-Bar-Baz"
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo-Bar-Baz",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseLongList(t *testing.T) {
+	input := `- one
+- two
+- three
+- four
+- five
+- six
+- seven
+- foo
+- bar
+- hello
+- world`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "one",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "two",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "three",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "four",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "five",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "six",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "seven",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "foo",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "bar",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "hello",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "world",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseMultiLevelList(t *testing.T) {
+	input := `- Foo
+  - Bar`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+							&List{
+								Marker: '-',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "Bar",
+											},
+										},
+										Marker: '-',
+									},
+								},
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseMultiLevelListLong(t *testing.T) {
+	input := `- Foo
+  - Bar
+    - Baz
+      - hello
+        - world
+          - test
+		    - long
+		      - list
+		        - here`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+							&List{
+								Marker: '-',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "Bar",
+											},
+											&List{
+												Marker: '-',
+												elements: []Node{
+													&ListElement{
+														children: []Node{
+															&Paragraph{
+																Text: "Baz",
+															},
+															&List{
+																Marker: '-',
+																elements: []Node{
+																	&ListElement{
+																		children: []Node{
+																			&Paragraph{
+																				Text: "hello",
+																			},
+																			&List{
+																				Marker: '-',
+																				elements: []Node{
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "world",
+																							},
+																							&List{
+																								Marker: '-',
+																								elements: []Node{
+																									&ListElement{
+																										children: []Node{
+																											&Paragraph{
+																												Text: "test",
+																											},
+																											&List{
+																												Marker: '-',
+																												elements: []Node{
+																													&ListElement{
+																														children: []Node{
+																															&Paragraph{
+																																Text: "long",
+																															},
+																															&List{
+																																Marker: '-',
+																																elements: []Node{
+																																	&ListElement{
+																																		children: []Node{
+																																			&Paragraph{
+																																				Text: "list",
+																																			},
+																																			&List{
+																																				Marker: '-',
+																																				elements: []Node{
+																																					&ListElement{
+																																						children: []Node{
+																																							&Paragraph{
+																																								Text: "here",
+																																							},
+																																						},
+																																						Marker: '-',
+																																					},
+																																				},
+																																			},
+																																		},
+																																		Marker: '-',
+																																	},
+																																},
+																															},
+																														},
+																														Marker: '-',
+																													},
+																												},
+																											},
+																										},
+																										Marker: '-',
+																									},
+																								},
+																							},
+																						},
+																						Marker: '-',
+																					},
+																				},
+																			},
+																		},
+																		Marker: '-',
+																	},
+																},
+															},
+														},
+														Marker: '-',
+													},
+												},
+											},
+										},
+										Marker: '-',
+									},
+								},
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseMultiLevelListLongComplex(t *testing.T) {
+	input := `- Foo
+  - Bar
+  - Bar2
+  - longer element
+    - Baz
+      - hello
+        - world
+        - foo
+        - foo
+        - foo
+        - foo
+          - test
+		    - long
+		      - list
+		        - here
+		          - even
+		            - more
+		              - elements
+		                - apple
+		                  - banana
+		                - coconut
+		              - test
+		              - abc
+		              - i
+		              - dont
+		              - know`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+							&List{
+								Marker: '-',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "Bar",
+											},
+										},
+										Marker: '-',
+									},
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "Bar2",
+											},
+										},
+										Marker: '-',
+									},
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "longer element",
+											},
+											&List{
+												Marker: '-',
+												elements: []Node{
+													&ListElement{
+														children: []Node{
+															&Paragraph{
+																Text: "Baz",
+															},
+															&List{
+																Marker: '-',
+																elements: []Node{
+																	&ListElement{
+																		children: []Node{
+																			&Paragraph{
+																				Text: "hello",
+																			},
+																			&List{
+																				Marker: '-',
+																				elements: []Node{
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "world",
+																							},
+																						},
+																						Marker: '-',
+																					},
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "foo",
+																							},
+																						},
+																						Marker: '-',
+																					},
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "foo",
+																							},
+																						},
+																						Marker: '-',
+																					},
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "foo",
+																							},
+																						},
+																						Marker: '-',
+																					},
+																					&ListElement{
+																						children: []Node{
+																							&Paragraph{
+																								Text: "foo",
+																							},
+																							&List{
+																								Marker: '-',
+																								elements: []Node{
+																									&ListElement{
+																										children: []Node{
+																											&Paragraph{
+																												Text: "test",
+																											},
+																											&List{
+																												Marker: '-',
+																												elements: []Node{
+																													&ListElement{
+																														children: []Node{
+																															&Paragraph{
+																																Text: "long",
+																															},
+																															&List{
+																																Marker: '-',
+																																elements: []Node{
+																																	&ListElement{
+																																		children: []Node{
+																																			&Paragraph{
+																																				Text: "list",
+																																			},
+																																			&List{
+																																				Marker: '-',
+																																				elements: []Node{
+																																					&ListElement{
+																																						children: []Node{
+																																							&Paragraph{
+																																								Text: "here",
+																																							},
+																																							&List{
+																																								Marker: '-',
+																																								elements: []Node{
+																																									&ListElement{
+																																										children: []Node{
+																																											&Paragraph{
+																																												Text: "even",
+																																											},
+																																											&List{
+																																												Marker: '-',
+																																												elements: []Node{
+																																													&ListElement{
+																																														children: []Node{
+																																															&Paragraph{
+																																																Text: "more",
+																																															},
+																																															&List{
+																																																Marker: '-',
+																																																elements: []Node{
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "elements",
+																																																			},
+																																																			&List{
+																																																				Marker: '-',
+																																																				elements: []Node{
+																																																					&ListElement{
+																																																						children: []Node{
+																																																							&Paragraph{
+																																																								Text: "apple",
+																																																							},
+																																																							&List{
+																																																								Marker: '-',
+																																																								elements: []Node{
+																																																									&ListElement{
+																																																										children: []Node{
+																																																											&Paragraph{
+																																																												Text: "banana",
+																																																											},
+																																																										},
+																																																										Marker: '-',
+																																																									},
+																																																								},
+																																																							},
+																																																						},
+																																																						Marker: '-',
+																																																					},
+																																																					&ListElement{
+																																																						children: []Node{
+																																																							&Paragraph{
+																																																								Text: "coconut",
+																																																							},
+																																																						},
+																																																						Marker: '-',
+																																																					},
+																																																				},
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "test",
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "abc",
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "i",
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "dont",
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																	&ListElement{
+																																																		children: []Node{
+																																																			&Paragraph{
+																																																				Text: "know",
+																																																			},
+																																																		},
+																																																		Marker: '-',
+																																																	},
+																																																},
+																																															},
+																																														},
+																																														Marker: '-',
+																																													},
+																																												},
+																																											},
+																																										},
+																																										Marker: '-',
+																																									},
+																																								},
+																																							},
+																																						},
+																																						Marker: '-',
+																																					},
+																																				},
+																																			},
+																																		},
+																																		Marker: '-',
+																																	},
+																																},
+																															},
+																														},
+																														Marker: '-',
+																													},
+																												},
+																											},
+																										},
+																										Marker: '-',
+																									},
+																								},
+																							},
+																						},
+																						Marker: '-',
+																					},
+																				},
+																			},
+																		},
+																		Marker: '-',
+																	},
+																},
+															},
+														},
+														Marker: '-',
+													},
+												},
+											},
+										},
+										Marker: '-',
+									},
+								},
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingWithList(t *testing.T) {
+	input := `# Heading
+
+- element 1
+- element 2
+- element 3
+`
+
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 1",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 2",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 3",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingWithListNoNL(t *testing.T) {
+	input := `# Heading
+- element 1
+- element 2
+- element 3
+`
+
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 1",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 2",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 3",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseHeadingWithListComplex(t *testing.T) {
+	input := `# Heading
+
+- element 1
+- element 2
+- element 3
+
+## Next heading
+- element 1
+### The lvl 3 heading
+
+- foo
+  - bar
+    - baz
+`
+
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Heading",
+			},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 1",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 2",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 3",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+			&Heading{
+				Level: 2,
+				Text:  "Next heading",
+			},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "element 1",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+			&Heading{
+				Level: 3,
+				Text:  "The lvl 3 heading",
+			},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "foo",
+							},
+							&List{
+								Marker: '-',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "bar",
+											},
+											&List{
+												Marker: '-',
+												elements: []Node{
+													&ListElement{
+														children: []Node{
+															&Paragraph{
+																Text: "baz",
+															},
+														},
+														Marker: '-',
+													},
+												},
+											},
+										},
+										Marker: '-',
+									},
+								},
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	input := "| Table |"
+	want := &Document{
+		children: []Node{
+			&Table{
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "Table",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTableTwoByTwo(t *testing.T) {
+	input := `| one | two |
+| three | four |`
+	want := &Document{
+		children: []Node{
+			&Table{
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "one",
+							},
+							&TableElement{
+								Text: "two",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "three",
+							},
+							&TableElement{
+								Text: "four",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTableTwoByTwoMissingValue(t *testing.T) {
+	input := `| one | |
+| three | four |`
+	want := &Document{
+		children: []Node{
+			&Table{
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "one",
+							},
+							&TableElement{
+								Text: "",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "three",
+							},
+							&TableElement{
+								Text: "four",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTableTwoByTwoMissingValueMoreWhitespace(t *testing.T) {
+	input := `| one |       |
+| three | four |`
+	want := &Document{
+		children: []Node{
+			&Table{
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "one",
+							},
+							&TableElement{
+								Text: "",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "three",
+							},
+							&TableElement{
+								Text: "four",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTableWithHeading(t *testing.T) {
+	input := `# Header
+| table header a | table header b |
+| ----- | ----- |
+| element a | element b |`
+	want := &Document{
+		children: []Node{
+			&Heading{
+				Level: 1,
+				Text:  "Header",
+			},
+			&Table{
+				Alignments:   []Alignment{AlignNone, AlignNone},
+				delimiterRow: 1,
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "table header a",
+							},
+							&TableElement{
+								Text: "table header b",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "-----",
+							},
+							&TableElement{
+								Text: "-----",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "element a",
+							},
+							&TableElement{
+								Text: "element b",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseCodeBlock(t *testing.T) {
+	input := "```shell\n# not a heading\n- not a list\n| not | a table |\n```"
+	want := &Document{
+		children: []Node{
+			&CodeBlock{
+				Info:        "shell",
+				FenceChar:   '`',
+				FenceLength: 3,
+				Text:        "# not a heading\n- not a list\n| not | a table |\n",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseCodeBlockTildeLongerFence(t *testing.T) {
+	input := "~~~~\n~~~\ncode\n~~~~~\nafter"
+	want := &Document{
+		children: []Node{
+			&CodeBlock{
+				Info:        "",
+				FenceChar:   '~',
+				FenceLength: 4,
+				Text:        "~~~\ncode\n",
+			},
+			&Paragraph{
+				Text: "after",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseCodeBlockInterruptsParagraph(t *testing.T) {
+	input := "some text\n```\ncode\n```"
+	want := &Document{
+		children: []Node{
+			&Paragraph{
+				Text: "some text",
+			},
+			&CodeBlock{
+				Info:        "",
+				FenceChar:   '`',
+				FenceLength: 3,
+				Text:        "code\n",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseOrderedList(t *testing.T) {
+	input := `1. one
+2. two
+   - nested
+3) three`
+	want := &Document{
+		children: []Node{
+			&List{
+				Ordered:   true,
+				Start:     1,
+				Delimiter: '.',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "one",
+							},
+						},
+						Ordered:   true,
+						Number:    1,
+						Delimiter: '.',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "two",
+							},
+							&List{
+								Marker: '-',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "nested",
+											},
+										},
+										Marker: '-',
+									},
+								},
+							},
+						},
+						Ordered:   true,
+						Number:    2,
+						Delimiter: '.',
+					},
+				},
+			},
+			&List{
+				Ordered:   true,
+				Start:     3,
+				Delimiter: ')',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "three",
+							},
+						},
+						Ordered:   true,
+						Number:    3,
+						Delimiter: ')',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseOrderedListInterruptsParagraph(t *testing.T) {
+	input := `In 2025
+2. is not a list
+1. but this is`
+	want := &Document{
+		children: []Node{
+			&Paragraph{
+				Text: "In 2025\n2. is not a list",
+			},
+			&List{
+				Ordered:   true,
+				Start:     1,
+				Delimiter: '.',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "but this is",
+							},
+						},
+						Ordered:   true,
+						Number:    1,
+						Delimiter: '.',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseAsteriskAndPlusList(t *testing.T) {
+	input := `* Foo
+  + Bar
+* Baz
+*emphasis*`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '*',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+							&List{
+								Marker: '+',
+								elements: []Node{
+									&ListElement{
+										children: []Node{
+											&Paragraph{
+												Text: "Bar",
+											},
+										},
+										Marker: '+',
+									},
+								},
+							},
+						},
+						Marker: '*',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Baz\n*emphasis*",
+							},
+						},
+						Marker: '*',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseListMarkerChangeStartsNewList(t *testing.T) {
+	input := `- Foo
++ Bar`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+			&List{
+				Marker: '+',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Bar",
+							},
+						},
+						Marker: '+',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseBlockquote(t *testing.T) {
+	input := `> # Quote
+> - item
+>> nested
+
+text`
+	want := &Document{
+		children: []Node{
+			&Blockquote{
+				children: []Node{
+					&Heading{
+						Level: 1,
+						Text:  "Quote",
+					},
+					&List{
+						Marker: '-',
+						elements: []Node{
+							&ListElement{
+								children: []Node{
+									&Paragraph{
+										Text: "item",
+									},
+								},
+								Marker: '-',
+							},
+						},
+					},
+					&Blockquote{
+						children: []Node{
+							&Paragraph{
+								Text: "nested",
+							},
+						},
+					},
+				},
+			},
+			&Paragraph{
+				Text: "text",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseListContinuationLine(t *testing.T) {
+	input := `- Foo
+  Bar
+Baz`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "Foo\nBar\nBaz",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseTableAlignments(t *testing.T) {
+	input := `| a | b | c | d |
+| --- | :-- | :-: | --: |`
+	want := []Alignment{AlignNone, AlignLeft, AlignCenter, AlignRight}
+	got := parse(input, DefaultFormatOptions()).Children()[0].(*Table).Alignments
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestParseTableEscapedPipeAndCodeSpan(t *testing.T) {
+	input := "| a \\| b | `x|y` | ``a`|`b`` |\n| c | d"
+	want := &Document{
+		children: []Node{
+			&Table{
+				rows: []Node{
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "a \\| b",
+							},
+							&TableElement{
+								Text: "`x|y`",
+							},
+							&TableElement{
+								Text: "``a`|`b``",
+							},
+						},
+					},
+					&TableRow{
+						elements: []Node{
+							&TableElement{
+								Text: "c",
+							},
+							&TableElement{
+								Text: "d",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseInvalidUTF8(t *testing.T) {
+	// Latin-1 is passed through byte by byte
+	input := "# caf\xe9\nna\xefve\n- \xff"
+	want := "# caf\xe9\n\nna\xefve\n\n- \xff\n"
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	if err := Format(&got, doc, DefaultFormatOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.String() != want {
+		t.Errorf("want %q, got %q", want, got.String())
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	input := "# Title\r\n\r\nsome text\r\nmore\n\n- a\n  - b\n    wrapped\n\n" +
+		"> quote\n> - x\n\n|  a | b |\n|---|--:|\n\n  ```go\ncode\n  ```\n"
+	doc := parse(input, DefaultFormatOptions())
+
+	want := []string{
+		input,
+		"# Title",
+		"some text\r\nmore",
+		"- a\n  - b\n    wrapped",
+		"- a\n  - b\n    wrapped",
+		"a",
+		"- b\n    wrapped",
+		"- b\n    wrapped",
+		"b\n    wrapped",
+		"> quote\n> - x",
+		"quote",
+		"- x",
+		"- x",
+		"x",
+		"|  a | b |\n|---|--:|",
+		"|  a | b |",
+		"a",
+		"b",
+		"|---|--:|",
+		"---",
+		"--:",
+		"```go\ncode\n  ```",
+	}
+	checkSpans(t, input, doc, want)
+}
+
+// checkSpans checks that the spans of the nodes below and including doc,
+// parsed from input, cover the text in want.
+func checkSpans(t *testing.T, input string, doc Node, want []string) {
+	t.Helper()
+
+	var nodes []Node
+	var collect func(n Node)
+	collect = func(n Node) {
+		nodes = append(nodes, n)
+		for _, c := range n.Children() {
+			collect(c)
+		}
+	}
+	collect(doc)
+
+	if len(nodes) != len(want) {
+		t.Fatalf("want %d nodes, got %d", len(want), len(nodes))
+	}
+
+	for i, n := range nodes {
+		span := n.Span()
+		if span.Start.Offset < 0 || span.Start.Offset > span.End.Offset || span.End.Offset > len(input) {
+			t.Errorf("node %d: span %+v is outside of the input", i, span)
+			continue
+		}
+		if got := input[span.Start.Offset:span.End.Offset]; got != want[i] {
+			t.Errorf("node %d: want %q, got %q", i, want[i], got)
+		}
+
+		// line and column must agree with the offset
+		for _, pos := range []Position{span.Start, span.End} {
+			lineStart := strings.LastIndex(input[:pos.Offset], "\n") + 1
+			line := strings.Count(input[:pos.Offset], "\n") + 1
+			if pos.Line != line || pos.Column != pos.Offset-lineStart+1 {
+				t.Errorf("node %d: position %+v, want line %d column %d", i, pos, line, pos.Offset-lineStart+1)
+			}
+		}
+	}
+}
+
+func TestParseListElementChildren(t *testing.T) {
+	input := "- one\n  ```go\n  x := 1\n  ```\n  > quoted\n- two"
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "one",
+							},
+							&CodeBlock{
+								Info:        "go",
+								FenceChar:   '`',
+								FenceLength: 3,
+								Text:        "x := 1\n",
+							},
+							&Blockquote{
+								children: []Node{
+									&Paragraph{
+										Text: "quoted",
+									},
+								},
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "two",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseListElementParagraphs(t *testing.T) {
+	input := `- one
+lazy
+
+  two
+- three
+
+
+text`
+	want := &Document{
+		children: []Node{
+			&List{
+				Marker: '-',
+				Loose:  true,
+				elements: []Node{
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "one\nlazy",
+							},
+							&Paragraph{
+								Text: "two",
+							},
+						},
+						Marker: '-',
+					},
+					&ListElement{
+						children: []Node{
+							&Paragraph{
+								Text: "three",
+							},
+						},
+						Marker: '-',
+					},
+				},
+			},
+			&Paragraph{
+				Text: "text",
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseListLooseness(t *testing.T) {
+	tests := []struct {
+		input string
+		loose bool
+	}{
+		{"- a\n- b", false},
+		{"- a\n\n- b", true},
+		{"- a\n  - b\n\n- c", true},
+		{"- a\n\n  b", true},
+		{"- a\n  ```\n  x\n\n  y\n  ```", false},
+		{"- a\n# heading", false},
+	}
+
+	for _, tt := range tests {
+		list := parse(tt.input, DefaultFormatOptions()).Children()[0].(*List)
+		if list.Loose != tt.loose {
+			t.Errorf("%q: want loose %v, got %v", tt.input, tt.loose, list.Loose)
+		}
+	}
+}
+
+func TestParseListNestedBeyondContentIndent(t *testing.T) {
+	nested := func(outer *ListElement) *Document {
+		outer.children = []Node{
+			&Paragraph{Text: "a"},
+			&List{
+				Marker: '-',
+				elements: []Node{
+					&ListElement{children: []Node{&Paragraph{Text: "b"}}, Marker: '-'},
+				},
+			},
+		}
+		list := &List{Marker: outer.Marker, Ordered: outer.Ordered, Start: outer.Number, Delimiter: outer.Delimiter, elements: []Node{outer}}
+		return &Document{children: []Node{list}}
+	}
+	bullet := func() *ListElement { return &ListElement{Marker: '-'} }
+	ordered := func() *ListElement { return &ListElement{Ordered: true, Number: 1, Delimiter: '.'} }
+
+	tests := []struct {
+		input string
+		want  *Document
+	}{
+		{"- a\n    - b", nested(bullet())},
+		{"- a\n\t- b", nested(bullet())},
+		{"1. a\n    - b", nested(ordered())},
+		{"1. a\n\t- b", nested(ordered())},
+	}
+
+	for _, test := range tests {
+		got := parse(test.input, DefaultFormatOptions())
+		if !equalIgnoringSpans(test.want, got) {
+			t.Logf("input %q", test.input)
+			dumpForTest(t, test.want, got)
+		}
+	}
+}
+
+func TestParseBlocksInterruptParagraph(t *testing.T) {
+	input := "Some text\n# Heading\nmore text\n| a | b |\n| - | - |\nend\n2. not a list"
+	want := &Document{
+		children: []Node{
+			&Paragraph{Text: "Some text"},
+			&Heading{Level: 1, Text: "Heading"},
+			&Paragraph{Text: "more text"},
+			&Table{
+				rows: []Node{
+					&TableRow{elements: []Node{&TableElement{Text: "a"}, &TableElement{Text: "b"}}},
+					&TableRow{elements: []Node{&TableElement{Text: "-"}, &TableElement{Text: "-"}}},
+				},
+				Alignments:   []Alignment{AlignNone, AlignNone},
+				delimiterRow: 1,
+			},
+			&Paragraph{Text: "end\n2. not a list"},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseParagraphKeepsHashAndPipeLines(t *testing.T) {
+	paragraph := func(text string) *Document {
+		return &Document{children: []Node{&Paragraph{Text: text}}}
+	}
+
+	tests := []struct {
+		input string
+		want  *Document
+	}{
+		{"This was fixed in pull request\n#123 last week.", paragraph("This was fixed in pull request\n#123 last week.")},
+		{"Use the pipe\n| to chain commands", paragraph("Use the pipe\n| to chain commands")},
+		{"#123 is fixed", paragraph("#123 is fixed")},
+		{"text\n####### seven", paragraph("text\n####### seven")},
+		{"> quote\n#123 lazy", &Document{children: []Node{
+			&Blockquote{children: []Node{&Paragraph{Text: "quote\n#123 lazy"}}},
+		}}},
+	}
+
+	for _, test := range tests {
+		got := parse(test.input, DefaultFormatOptions())
+		if !equalIgnoringSpans(test.want, got) {
+			t.Logf("input %q", test.input)
+			dumpForTest(t, test.want, got)
+		}
+	}
+}
+
+func TestParseThematicBreakIsNoList(t *testing.T) {
+	input := "para\n\n---\n\nTitle\n---\n- a\n\n* * *\n\n- b\n- - -"
+	want := &Document{
+		children: []Node{
+			&Paragraph{Text: "para"},
+			&Paragraph{Text: "---"},
+			&Paragraph{Text: "Title\n---"},
+			&List{
+				Marker:   '-',
+				elements: []Node{&ListElement{children: []Node{&Paragraph{Text: "a"}}, Marker: '-'}},
+			},
+			&Paragraph{Text: "* * *"},
+			&List{
+				Marker:   '-',
+				elements: []Node{&ListElement{children: []Node{&Paragraph{Text: "b"}}, Marker: '-'}},
+			},
+			&Paragraph{Text: "- - -"},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
+
+func TestParseSpansTabIndented(t *testing.T) {
+	input := "- a\n\tlazy\n- b\n\t- c\n\t  more\n\n\t  end"
+	doc := parse(input, DefaultFormatOptions())
+
+	want := []string{
+		input,
+		"- a\n\tlazy\n- b\n\t- c\n\t  more\n\n\t  end",
+		"- a\n\tlazy",
+		"a\n\tlazy",
+		"- b\n\t- c\n\t  more\n\n\t  end",
+		"b",
+		"- c\n\t  more\n\n\t  end",
+		"- c\n\t  more\n\n\t  end",
+		"c\n\t  more",
+		"end",
+	}
+	checkSpans(t, input, doc, want)
+}
+
+func TestParseManyLazyLines(t *testing.T) {
+	// re-parsing the element for every lazy line took minutes for this
+	input := "- a\n  - b\n    - c\n      - d\n" + strings.Repeat("lazy\n", 3000)
+
+	got := parse(input, DefaultFormatOptions())
+
+	var last Node = got
+	for last.Type() != NodeTypeParagraph {
+		children := last.Children()
+		last = children[len(children)-1]
+	}
+	if want := "d" + strings.Repeat("\nlazy", 3000); last.(*Paragraph).Text != want {
+		t.Errorf("want the lazy lines in the innermost paragraph, got %q", last.(*Paragraph).Text)
+	}
+}
+
+func TestParseBlockquoteLazyContinuation(t *testing.T) {
+	input := "> > deep\n> back\nlazy\n***\n> next"
+	want := &Document{
+		children: []Node{
+			&Blockquote{
+				children: []Node{
+					&Blockquote{
+						children: []Node{
+							&Paragraph{Text: "deep\nback\nlazy"},
+						},
+					},
+				},
+			},
+			&Paragraph{Text: "***"},
+			&Blockquote{
+				children: []Node{
+					&Paragraph{Text: "next"},
+				},
+			},
+		},
+	}
+	got := parse(input, DefaultFormatOptions())
+
+	if !equalIgnoringSpans(want, got) {
+		dumpForTest(t, want, got)
+	}
+}
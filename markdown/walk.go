@@ -0,0 +1,93 @@
+package markdown
+
+// A Visitor is called by Walk for every node of a tree.
+type Visitor interface {
+	// Enter is called before the children of node are visited, they are
+	// skipped if it returns false.
+	Enter(node Node) bool
+	// Leave is called after the children of node, or right after Enter if
+	// they were skipped.
+	Leave(node Node)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, calling
+// v.Enter and v.Leave for every node.
+func Walk(node Node, v Visitor) {
+	if v.Enter(node) {
+		for _, child := range node.Children() {
+			Walk(child, v)
+		}
+	}
+	v.Leave(node)
+}
+
+// inspector adapts a function to a Visitor for Inspect, entered records
+// for every node being visited whether f returned true for it.
+type inspector struct {
+	f       func(Node) bool
+	entered []bool
+}
+
+func (in *inspector) Enter(node Node) bool {
+	ok := in.f(node)
+	in.entered = append(in.entered, ok)
+	return ok
+}
+
+func (in *inspector) Leave(node Node) {
+	ok := in.entered[len(in.entered)-1]
+	in.entered = in.entered[:len(in.entered)-1]
+	if ok {
+		in.f(nil)
+	}
+}
+
+// Inspect traverses the tree rooted at node in depth-first order like
+// go/ast.Inspect: it calls f(node) and, if that returns true, inspects the
+// children of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, &inspector{f: f})
+}
+
+// Rewrite calls f for every node of the tree rooted at node, children before
+// their parents, and replaces the node with the result of f. Returning the
+// node keeps it, returning nil deletes it. Rewrite returns the new root.
+//
+// A replacement must fit its parent: lists hold *ListElement, tables
+// *TableRow and rows *TableElement nodes only.
+func Rewrite(node Node, f func(Node) Node) Node {
+	children := node.Children()
+	if len(children) > 0 {
+		rewritten := make([]Node, 0, len(children))
+		for _, child := range children {
+			if child = Rewrite(child, f); child != nil {
+				rewritten = append(rewritten, child)
+			}
+		}
+		setChildren(node, rewritten)
+	}
+
+	return f(node)
+}
+
+// setChildren replaces the children of node.
+func setChildren(node Node, children []Node) {
+	switch n := node.(type) {
+	case *Document:
+		n.children = children
+	case *List:
+		n.elements = children
+	case *ListElement:
+		n.children = children
+	case *Table:
+		// the delimiter row keeps its index if it stays second
+		if n.delimiterRow > 0 && (len(children) <= n.delimiterRow || children[n.delimiterRow] != n.rows[n.delimiterRow]) {
+			n.delimiterRow = 0
+		}
+		n.rows = children
+	case *TableRow:
+		n.elements = children
+	case *Blockquote:
+		n.children = children
+	}
+}
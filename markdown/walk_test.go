@@ -0,0 +1,110 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorder records the calls of Walk and skips the children of lists.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) Enter(node Node) bool {
+	r.calls = append(r.calls, "enter "+nodeName(node))
+	return node.Type() != NodeTypeList
+}
+
+func (r *recorder) Leave(node Node) {
+	r.calls = append(r.calls, "leave "+nodeName(node))
+}
+
+func nodeName(node Node) string {
+	return strings.TrimPrefix(reflect.TypeOf(node).String(), "*markdown.")
+}
+
+func TestWalk(t *testing.T) {
+	doc := parse("# title\n\n- a\n- b\n\n> quote", DefaultFormatOptions())
+	want := []string{
+		"enter Document",
+		"enter Heading",
+		"leave Heading",
+		"enter List",
+		"leave List",
+		"enter Blockquote",
+		"enter Paragraph",
+		"leave Paragraph",
+		"leave Blockquote",
+		"leave Document",
+	}
+
+	r := &recorder{}
+	Walk(doc, r)
+
+	if !reflect.DeepEqual(want, r.calls) {
+		t.Errorf("want\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(r.calls, "\n"))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	doc := parse("text\n\n- a\n  - b\n\n| x | y |", DefaultFormatOptions())
+
+	paragraphs, nils := 0, 0
+	Inspect(doc, func(node Node) bool {
+		switch {
+		case node == nil:
+			nils++
+		case node.Type() == NodeTypeParagraph:
+			paragraphs++
+		}
+		// tables hold no paragraphs
+		return node == nil || node.Type() != NodeTypeTable
+	})
+
+	if paragraphs != 3 {
+		t.Errorf("want 3 paragraphs, got %d", paragraphs)
+	}
+	// Document, Paragraph, 2 Lists, 2 ListElements, 2 Paragraphs but not the
+	// skipped Table
+	if nils != 8 {
+		t.Errorf("want 8 calls with nil, got %d", nils)
+	}
+}
+
+func TestRewriteTableHeader(t *testing.T) {
+	input := "| a |\n|--:|\n| - |"
+	doc := parse(input, DefaultFormatOptions())
+	header := doc.Children()[0].Children()[0]
+
+	doc = Rewrite(doc, func(node Node) Node {
+		if node == header {
+			return nil
+		}
+		return node
+	}).(*Document)
+
+	// the former delimiter row is the header now, the last row stays
+	if want, got := "| --: |\n| --: |\n|   - |", Fmt(doc, DefaultFormatOptions()); want != got {
+		printFmtForTest(t, want, got, doc)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	input := "# Title\n\n```\ncode\n```\n\n- ## item\n- ```\n  more code\n  ```\n\n> ```\n> quoted code\n> ```"
+	want := "## Title\n\n- ### item\n-\n\n>"
+
+	doc := Rewrite(parse(input, DefaultFormatOptions()), func(node Node) Node {
+		switch n := node.(type) {
+		case *Heading:
+			n.Level++
+		case *CodeBlock:
+			return nil
+		}
+		return node
+	})
+
+	if got := Fmt(doc, DefaultFormatOptions()); want != got {
+		printFmtForTest(t, want, got, doc)
+	}
+}
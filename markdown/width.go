@@ -0,0 +1,181 @@
+package markdown
+
+import (
+	"sort"
+	"unicode"
+)
+
+// wideRanges are the East Asian Wide and Fullwidth code points, including
+// emoji with default emoji presentation, which take two terminal columns.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2329, 0x232A},
+	{0x23E9, 0x23EC},
+	{0x23F0, 0x23F0},
+	{0x23F3, 0x23F3},
+	{0x25FD, 0x25FE},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267F, 0x267F},
+	{0x2693, 0x2693},
+	{0x26A1, 0x26A1},
+	{0x26AA, 0x26AB},
+	{0x26BD, 0x26BE},
+	{0x26C4, 0x26C5},
+	{0x26CE, 0x26CE},
+	{0x26D4, 0x26D4},
+	{0x26EA, 0x26EA},
+	{0x26F2, 0x26F3},
+	{0x26F5, 0x26F5},
+	{0x26FA, 0x26FA},
+	{0x26FD, 0x26FD},
+	{0x2705, 0x2705},
+	{0x270A, 0x270B},
+	{0x2728, 0x2728},
+	{0x274C, 0x274C},
+	{0x274E, 0x274E},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27B0, 0x27B0},
+	{0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C},
+	{0x2B50, 0x2B50},
+	{0x2B55, 0x2B55},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF},
+	{0x1B000, 0x1B2FF},
+	{0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A},
+	{0x1F200, 0x1F202},
+	{0x1F210, 0x1F23B},
+	{0x1F240, 0x1F248},
+	{0x1F250, 0x1F251},
+	{0x1F260, 0x1F265},
+	{0x1F300, 0x1F320},
+	{0x1F32D, 0x1F335},
+	{0x1F337, 0x1F37C},
+	{0x1F37E, 0x1F393},
+	{0x1F3A0, 0x1F3CA},
+	{0x1F3CF, 0x1F3D3},
+	{0x1F3E0, 0x1F3F0},
+	{0x1F3F4, 0x1F3F4},
+	{0x1F3F8, 0x1F43E},
+	{0x1F440, 0x1F440},
+	{0x1F442, 0x1F4FC},
+	{0x1F4FF, 0x1F53D},
+	{0x1F54B, 0x1F54E},
+	{0x1F550, 0x1F567},
+	{0x1F57A, 0x1F57A},
+	{0x1F595, 0x1F596},
+	{0x1F5A4, 0x1F5A4},
+	{0x1F5FB, 0x1F64F},
+	{0x1F680, 0x1F6C5},
+	{0x1F6CC, 0x1F6CC},
+	{0x1F6D0, 0x1F6D2},
+	{0x1F6D5, 0x1F6D7},
+	{0x1F6DC, 0x1F6DF},
+	{0x1F6EB, 0x1F6EC},
+	{0x1F6F4, 0x1F6FC},
+	{0x1F7E0, 0x1F7EB},
+	{0x1F7F0, 0x1F7F0},
+	{0x1F90C, 0x1F93A},
+	{0x1F93C, 0x1F945},
+	{0x1F947, 0x1F9FF},
+	{0x1FA70, 0x1FAFF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of terminal columns r takes on its own.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7F:
+		return 0
+	case r < 0x1100:
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+			return 0
+		}
+		return 1
+	// medial vowels and final consonants of decomposed Hangul syllables
+	case r >= 0x1160 && r <= 0x11FF:
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+
+	i := sort.Search(len(wideRanges), func(i int) bool { return wideRanges[i].hi >= r })
+	if i < len(wideRanges) && wideRanges[i].lo <= r {
+		return 2
+	}
+
+	return 1
+}
+
+// displayWidth returns the number of terminal columns s takes. Combining
+// marks are zero width and emoji sequences (joined with ZWJ, with skin tone
+// modifiers, variation selectors or as regional indicator pairs) count as a
+// single wide character.
+func displayWidth(s string) int {
+	width := 0
+	clusterWidth := 0 // width of the previous user-perceived character
+	joined := false   // previous rune was a zero width joiner
+	flag := false     // previous rune was the first half of a flag
+
+	for _, r := range s {
+		switch {
+		case joined:
+			joined = false
+			continue
+		case r == 0x200D:
+			joined = true
+			continue
+		// emoji presentation selector
+		case r == 0xFE0F:
+			if clusterWidth == 1 {
+				width++
+				clusterWidth = 2
+			}
+			continue
+		// skin tone modifiers
+		case r >= 0x1F3FB && r <= 0x1F3FF && clusterWidth == 2:
+			continue
+		// regional indicators, two make up a flag
+		case r >= 0x1F1E6 && r <= 0x1F1FF:
+			if flag {
+				flag = false
+				continue
+			}
+			flag = true
+			width += 2
+			clusterWidth = 2
+			continue
+		}
+
+		flag = false
+		w := runeWidth(r)
+		if w == 0 {
+			continue
+		}
+
+		width += w
+		clusterWidth = w
+	}
+
+	return width
+}
@@ -0,0 +1,32 @@
+package markdown
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Größe", 5},
+		{"a\u0308", 1}, // a + combining diaeresis
+		{"→", 1},
+		{"日本語", 6},
+		{"ｶﾀｶﾅ", 4}, // halfwidth katakana
+		{"ＡＢ", 4},   // fullwidth latin
+		{"한국어", 6},
+		{"😀", 2},
+		{"👍🏽", 2},       // skin tone modifier
+		{"❤️", 2},       // text default with emoji presentation selector
+		{"👨‍👩‍👧", 2},    // zero width joiner sequence
+		{"🇩🇪🇯🇵", 4},     // two flags
+		{"a\u200bb", 2}, // zero width space
+	}
+
+	for _, tt := range tests {
+		if got := displayWidth(tt.in); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}